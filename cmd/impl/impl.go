@@ -0,0 +1,315 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The impl command generates the method stubs a concrete type needs to
+// implement an interface.  It is a command-line interface to
+// golang.org/x/tools/refactor/impl.
+package main // import "golang.org/x/tools/cmd/impl"
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/refactor/impl"
+)
+
+var (
+	ifaceFlag = flag.String("iface", "", "the interface to implement, as import/path.Name (e.g. io.ReadCloser)")
+	pkgFlag   = flag.String("pkg", "", "import path of the package containing the concrete type")
+	fileFlag  = flag.String("file", "", "base name of the file declaring the concrete type")
+	recvFlag  = flag.String("recv", "", "receiver of the generated methods (e.g. 'f *File')")
+	jsonFlag  = flag.Bool("json", false, "emit a machine-readable JSON object instead of the new file contents")
+	writeFlag = flag.Bool("w", false, "rewrite the file in place (by default, the result is printed to standard output)")
+	allFlag   = flag.Bool("all", false, "with no -recv, stub every struct type in the package instead of only asserted ones")
+	dirFlag   = flag.Bool("directives", false, "stub every type annotated with an //impl:path.Name directive; -iface is not needed")
+)
+
+func init() {
+	flag.Var((*buildutil.TagsFlag)(&build.Default.BuildTags), "tags", buildutil.TagsFlagDoc)
+}
+
+const usage = `impl: generate method stubs for implementing an interface.
+
+Usage: impl -iface io.ReadCloser -pkg <import path> -file <file.go> -recv 'f *File' [-json] [-w]
+
+With no -recv, every type in the package asserted against the interface
+(var _ I = ...) is stubbed in one run; -all extends that to every struct
+type in the package.  -directives instead stubs every type annotated
+with an //impl:path.Name comment, using the interface each directive
+names.
+`
+
+func main() {
+	flag.Parse()
+	var err error
+	if *dirFlag {
+		err = doDirectives(&build.Default, os.Stdout, *pkgFlag, *writeFlag)
+	} else if *recvFlag == "" {
+		err = doBatch(&build.Default, os.Stdout, *ifaceFlag, *pkgFlag, *allFlag, *writeFlag)
+	} else {
+		err = doMain(&build.Default, os.Stdout, *ifaceFlag, *pkgFlag, *fileFlag, *recvFlag, *jsonFlag, *writeFlag)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "impl: %s\n", err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+}
+
+// jsonOutput is the object emitted by -json.  Editors apply the change
+// by inserting Methods at Offset in File and adding Imports, without
+// re-running gofmt over the rest of the file.
+type jsonOutput struct {
+	File    string       `json:"file"`    // path of the target file
+	Offset  int          `json:"offset"`  // byte offset at which to insert Methods
+	Methods string       `json:"methods"` // the formatted method stubs
+	Imports []jsonImport `json:"imports,omitempty"`
+}
+
+// A jsonImport is an import the stubs require, with the local name
+// under which it must be added.
+type jsonImport struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func doMain(ctxt *build.Context, out io.Writer, iface, pkg, file, recv string, jsonMode, write bool) error {
+	dot := strings.LastIndex(iface, ".")
+	if dot < 0 {
+		return fmt.Errorf("-iface must have the form import/path.Name, got %q", iface)
+	}
+	resp, err := impl.Implement(&impl.Request{
+		Ctxt:         ctxt,
+		IfacePath:    iface[:dot],
+		IfaceName:    iface[dot+1:],
+		ConcretePath: pkg,
+		File:         file,
+		Target:       recv,
+		Granular:     jsonMode,
+	})
+	if err != nil {
+		return err
+	}
+
+	bp, err := ctxt.Import(pkg, "", build.FindOnly)
+	if err != nil {
+		return err
+	}
+	filename := joinPath(ctxt, bp.Dir, file)
+
+	if jsonMode {
+		output := jsonOutput{
+			File:    filename,
+			Offset:  resp.Offset,
+			Methods: string(resp.Methods),
+		}
+		for _, imp := range resp.Imports {
+			output.Imports = append(output.Imports, jsonImport{Name: imp.Name, Path: imp.Path})
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "\t")
+		return enc.Encode(output)
+	}
+
+	if write {
+		return ioutil.WriteFile(filename, resp.File, 0644)
+	}
+	_, err = out.Write(resp.File)
+	return err
+}
+
+// doBatch stubs several types of the package in one run: those asserted
+// against the interface or, with all set, every struct type.  Each
+// type's stubs are generated against an overlay carrying the previous
+// results, so several types declared in the same file accumulate
+// correctly.  Per-type failures are collected rather than aborting the
+// run, and reported together after the successful files are emitted.
+func doBatch(ctxt *build.Context, out io.Writer, iface, pkg string, all, write bool) error {
+	dot := strings.LastIndex(iface, ".")
+	if dot < 0 {
+		return fmt.Errorf("-iface must have the form import/path.Name, got %q", iface)
+	}
+	ipath, iname := iface[:dot], iface[dot+1:]
+
+	type target struct {
+		name      string   // the concrete type
+		filename  string   // full path of its declaring file
+		recv      string   // receiver for the generated methods
+		ifaceArgs []string // type arguments of a generic interface assertion
+	}
+	var targets []target
+	if all {
+		conf := loader.Config{Build: ctxt, AllowErrors: true}
+		conf.TypeChecker.Error = func(error) {}
+		conf.Import(pkg)
+		prog, err := conf.Load()
+		if err != nil {
+			return err
+		}
+		info := prog.Package(pkg)
+		scope := info.Pkg.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj.IsAlias() {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok || types.IsInterface(named) {
+				continue
+			}
+			if _, ok := named.Underlying().(*types.Struct); !ok {
+				continue
+			}
+			targets = append(targets, target{
+				name:     name,
+				filename: prog.Fset.Position(obj.Pos()).Filename,
+				recv:     "*" + name,
+			})
+		}
+	} else {
+		assertions, err := impl.FindAssertions(ctxt, pkg)
+		if err != nil {
+			return err
+		}
+		for _, a := range assertions {
+			if a.IfacePath != ipath || a.IfaceName != iname || !a.Missing {
+				continue
+			}
+			targets = append(targets, target{
+				name:      strings.TrimPrefix(a.Target, "*"),
+				filename:  a.Pos.Filename,
+				recv:      a.Target,
+				ifaceArgs: a.IfaceTypeArgs,
+			})
+		}
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no types to implement %s in package %q", iface, pkg)
+	}
+
+	overlay := make(map[string][]byte)
+	work := buildutil.OverlayContext(ctxt, overlay)
+	modified := make(map[string][]byte)
+	var failures []string
+	for _, t := range targets {
+		resp, err := impl.Implement(&impl.Request{
+			Ctxt:          work,
+			IfacePath:     ipath,
+			IfaceName:     iname,
+			IfaceTypeArgs: t.ifaceArgs,
+			ConcretePath:  pkg,
+			File:          filepath.Base(t.filename),
+			Target:        t.recv,
+			AllowErrors:   true,
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", t.name, err))
+			continue
+		}
+		overlay[t.filename] = resp.File
+		modified[t.filename] = resp.File
+	}
+
+	var filenames []string
+	for filename := range modified {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if write {
+			if err := ioutil.WriteFile(filename, modified[filename], 0644); err != nil {
+				return err
+			}
+		} else if _, err := out.Write(modified[filename]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "impl: %d type(s) stubbed, %d failure(s)\n", len(targets)-len(failures), len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// doDirectives stubs every type in the package carrying an //impl:
+// directive, against the interface the directive names.  Like doBatch,
+// results accumulate through an overlay and per-type failures are
+// collected rather than aborting the run.
+func doDirectives(ctxt *build.Context, out io.Writer, pkg string, write bool) error {
+	directives, err := impl.FindImplDirectives(ctxt, pkg)
+	if err != nil {
+		return err
+	}
+	var pending []*impl.ImplDirective
+	for _, d := range directives {
+		if d.Missing {
+			pending = append(pending, d)
+		}
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("no //impl: directives to implement in package %q", pkg)
+	}
+
+	overlay := make(map[string][]byte)
+	work := buildutil.OverlayContext(ctxt, overlay)
+	modified := make(map[string][]byte)
+	var failures []string
+	for _, d := range pending {
+		resp, err := impl.Implement(&impl.Request{
+			Ctxt:         work,
+			IfacePath:    d.IfacePath,
+			IfaceName:    d.IfaceName,
+			ConcretePath: pkg,
+			File:         filepath.Base(d.Pos.Filename),
+			Target:       d.Target,
+			AllowErrors:  true,
+		})
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", d.Target, err))
+			continue
+		}
+		overlay[d.Pos.Filename] = resp.File
+		modified[d.Pos.Filename] = resp.File
+	}
+
+	var filenames []string
+	for filename := range modified {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if write {
+			if err := ioutil.WriteFile(filename, modified[filename], 0644); err != nil {
+				return err
+			}
+		} else if _, err := out.Write(modified[filename]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "impl: %d type(s) stubbed, %d failure(s)\n", len(pending)-len(failures), len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// joinPath joins path elements through the build context, so fake file
+// systems are honored.
+func joinPath(ctxt *build.Context, elem ...string) string {
+	if ctxt.JoinPath != nil {
+		return ctxt.JoinPath(elem...)
+	}
+	return filepath.Join(elem...)
+}
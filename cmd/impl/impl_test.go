@@ -0,0 +1,127 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+func TestJSONMode(t *testing.T) {
+	ctxt := buildutil.FakeContext(map[string]map[string]string{
+		"time": {"0.go": `package time
+
+type Duration int64`},
+		"iface": {"0.go": `package iface
+
+import "time"
+
+type Waiter interface {
+	Wait(d time.Duration) error
+}`},
+		"conc": {"0.go": `package conc
+
+type Clock struct{}`},
+	})
+
+	var out bytes.Buffer
+	if err := doMain(ctxt, &out, "iface.Waiter", "conc", "0.go", "c *Clock", true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		File    string `json:"file"`
+		Offset  int    `json:"offset"`
+		Methods string `json:"methods"`
+		Imports []struct {
+			Name string `json:"name"`
+			Path string `json:"path"`
+		} `json:"imports"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out.Bytes())
+	}
+	if !strings.HasSuffix(got.File, "/conc/0.go") {
+		t.Errorf("file = %q, want path ending in /conc/0.go", got.File)
+	}
+	if got.Offset == 0 {
+		t.Error("offset = 0, want end of original file")
+	}
+	if !strings.Contains(got.Methods, "func (c *Clock) Wait(d time.Duration) error {") {
+		t.Errorf("methods missing Wait stub:\n%s", got.Methods)
+	}
+	if len(got.Imports) != 1 || got.Imports[0].Name != "time" || got.Imports[0].Path != "time" {
+		t.Errorf("imports = %+v, want [{time time}]", got.Imports)
+	}
+}
+
+// TestDoBatch stubs two asserted types of one package in a single run.
+func TestDoBatch(t *testing.T) {
+	ctxt := buildutil.FakeContext(map[string]map[string]string{
+		"iface": {"i.go": `package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {"c.go": `package conc
+
+import "iface"
+
+type A struct{}
+
+type B struct{}
+
+var _ iface.Runner = &A{}
+var _ iface.Runner = &B{}`},
+	})
+
+	var out bytes.Buffer
+	if err := doBatch(ctxt, &out, "iface.Runner", "conc", false, false); err != nil {
+		t.Fatal(err)
+	}
+	src := out.String()
+	for _, want := range []string{
+		"func (a *A) Run() error {",
+		"func (b *B) Run() error {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("batch output missing %q:\n%s", want, src)
+		}
+	}
+}
+
+// TestDoBatchGenericInterface checks that an assertion instantiating a
+// generic interface is stubbed with its type arguments rather than
+// reported as a failure.
+func TestDoBatchGenericInterface(t *testing.T) {
+	ctxt := buildutil.FakeContext(map[string]map[string]string{
+		"iface": {"i.go": `package iface
+
+type Handler[T any] interface {
+	Handle(v T) error
+}`},
+		"conc": {"c.go": `package conc
+
+import "iface"
+
+type Event struct{}
+
+type Processor struct{}
+
+var _ iface.Handler[Event] = (*Processor)(nil)`},
+	})
+
+	var out bytes.Buffer
+	if err := doBatch(ctxt, &out, "iface.Handler", "conc", false, false); err != nil {
+		t.Fatal(err)
+	}
+	if src := out.String(); !strings.Contains(src, "func (p *Processor) Handle(v Event) error {") {
+		t.Errorf("type argument not forwarded to the stub:\n%s", src)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A Directive is one //go:generate comment found in a Go source file.
+type Directive struct {
+	Line int      // 1-based line of the comment
+	Raw  string   // the command text after the marker, as written
+	Args []string // the command tokenized, with quoting resolved
+}
+
+// Directives returns the //go:generate directives in a file's
+// contents, each with its command tokenized the way the go tool does:
+// arguments are separated by spaces and tabs, and a double-quoted
+// argument is unquoted with Go string syntax, so it may contain
+// spaces.  A directive whose text ends in a backslash continues on
+// the next comment line.  Editors use this to offer a menu of the
+// file's generators and per-directive runs.
+func Directives(src []byte) ([]Directive, error) {
+	const marker = "//go:generate"
+	var dirs []Directive
+	lines := strings.Split(string(src), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, marker) {
+			continue
+		}
+		rest := line[len(marker):]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+			continue // e.g. //go:generatefoo
+		}
+		start := i + 1
+		text := strings.TrimSpace(rest)
+		for strings.HasSuffix(text, "\\") && i+1 < len(lines) {
+			i++
+			text = strings.TrimSuffix(text, "\\") + " " +
+				strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), "//"))
+		}
+		args, err := splitArgs(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		dirs = append(dirs, Directive{Line: start, Raw: text, Args: args})
+	}
+	return dirs, nil
+}
+
+// splitArgs tokenizes a directive's command text.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t':
+			i++
+		case '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("mismatched quote in %q", s)
+			}
+			arg, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted argument %s: %v", s[i:j+1], err)
+			}
+			args = append(args, arg)
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && s[j] != ' ' && s[j] != '\t' {
+				j++
+			}
+			args = append(args, s[i:j])
+			i = j
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDirectives(t *testing.T) {
+	src := []byte(`// Copyright.
+
+//go:generate stringer -type=Pill
+package p
+
+//go:generate mockgen -destination "out dir/mock.go" -package p
+// go:generate not-a-directive
+//go:generatefoo also-not-a-directive
+`)
+	got, err := Directives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Directive{
+		{Line: 3, Raw: "stringer -type=Pill", Args: []string{"stringer", "-type=Pill"}},
+		{Line: 6, Raw: `mockgen -destination "out dir/mock.go" -package p`,
+			Args: []string{"mockgen", "-destination", "out dir/mock.go", "-package", "p"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Directives = %+v, want %+v", got, want)
+	}
+}
+
+func TestDirectiveContinuation(t *testing.T) {
+	src := []byte(`package p
+
+//go:generate protoc --go_out=. \
+//	--go_opt=paths=source_relative api.proto
+`)
+	got, err := Directives(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d directives, want 1", len(got))
+	}
+	want := []string{"protoc", "--go_out=.", "--go_opt=paths=source_relative", "api.proto"}
+	if !reflect.DeepEqual(got[0].Args, want) {
+		t.Errorf("Args = %v, want %v", got[0].Args, want)
+	}
+}
+
+func TestDirectivesBadQuote(t *testing.T) {
+	if _, err := Directives([]byte("//go:generate gen \"unclosed\npackage p\n")); err == nil {
+		t.Error("mismatched quote parsed without error")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package generate runs the go generate command on behalf of editor
+// integrations and reports what it did.
+package generate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Request describes a single go generate invocation.
+type Request struct {
+	// Dir is the directory in which to run the command.
+	Dir string
+
+	// Recursive runs go generate over ./... instead of the directory
+	// alone.
+	Recursive bool
+
+	// File, if non-empty, restricts generation to the directives in
+	// that one file (go generate <file>).  It takes precedence over
+	// Recursive: a single file is already as narrow as generation
+	// gets.
+	File string
+
+	// Pattern, if non-empty, is passed to go generate as its -run
+	// flag: only directives whose command matches the regexp are run.
+	// Integrations use it to run a single tool's directives, such as
+	// all mockgen commands, across the tree.
+	Pattern string
+
+	// Progress, if non-nil, receives the command's output one
+	// complete line at a time while it runs.
+	Progress func(message string)
+
+	// Log, if non-nil, receives the run's log messages.  Failures are
+	// always reported through Run's error; informational messages are
+	// sent only when Verbose is set, so integrations can honor the
+	// user's configured verbosity.
+	Log func(format string, args ...interface{})
+
+	// Verbose enables the informational log messages.
+	Verbose bool
+}
+
+// logf sends an informational message, subject to the request's
+// verbosity.
+func (r *Request) logf(format string, args ...interface{}) {
+	if r.Verbose && r.Log != nil {
+		r.Log(format, args...)
+	}
+}
+
+// Args returns the arguments of the go command line that the request
+// describes, excluding the "go" command itself.
+func (r *Request) Args() []string {
+	args := []string{"generate"}
+	if r.Pattern != "" {
+		args = append(args, "-run", r.Pattern)
+	}
+	switch {
+	case r.File != "":
+		args = append(args, r.File)
+	case r.Recursive:
+		args = append(args, "./...")
+	default:
+		args = append(args, ".")
+	}
+	return args
+}
+
+// A Result reports what a generate run did.
+type Result struct {
+	// Modified lists the files, relative to the request's directory,
+	// that were created or changed during the run.
+	Modified []string
+}
+
+// Run executes the request, returning an error that includes the
+// command's output if it fails.  On success the result lists the files
+// the generators modified, determined by comparing a snapshot of the
+// directory taken before the run against one taken after.
+func (r *Request) Run() (*Result, error) {
+	return r.RunContext(context.Background())
+}
+
+// RunContext is Run under a context: cancelling ctx kills the go
+// generate subprocess.  A Tracker's Start context belongs here, so
+// that Cancel interrupts the work it tracks.
+func (r *Request) RunContext(ctx context.Context) (*Result, error) {
+	// A malformed pattern would otherwise surface as an opaque go
+	// command failure; reject it up front.
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("go generate: invalid -run pattern: %v", err)
+		}
+	}
+	// The directory comes from the client; validate it before handing
+	// it to the go command.  The path is passed via cmd.Dir and never
+	// through a shell, so spaces and other special characters in it
+	// need no quoting.
+	switch info, err := os.Stat(r.Dir); {
+	case err != nil:
+		return nil, fmt.Errorf("go generate: directory %q: %v", r.Dir, err)
+	case !info.IsDir():
+		return nil, fmt.Errorf("go generate: %q is not a directory", r.Dir)
+	}
+	before, err := snapshot(r.Dir, r.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "go", r.Args()...)
+	cmd.Dir = r.Dir
+	r.logf("generate: running go %s in %s", strings.Join(r.Args(), " "), r.Dir)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if r.Progress != nil {
+		// Report complete lines as they arrive, while still
+		// collecting everything for the error message.
+		pw := &ProgressWriter{Report: r.Progress}
+		defer pw.Flush()
+		cmd.Stdout = io.MultiWriter(&out, pw)
+		cmd.Stderr = cmd.Stdout
+	}
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go generate: %v\n%s", err, out.Bytes())
+	}
+	after, err := snapshot(r.Dir, r.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	modified := changedFiles(before, after)
+	r.logf("generate: done, %d files modified", len(modified))
+	return &Result{Modified: modified}, nil
+}
+
+// A fileStamp records enough about a file to tell whether it changed.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshot records the stamp of every regular file under dir.  If
+// recursive is false, only dir itself is examined.
+func snapshot(dir string, recursive bool) (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if !recursive && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		stamps[rel] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stamps, nil
+}
+
+// changedFiles returns, sorted, the files that are new in after or
+// whose stamp differs from before.
+func changedFiles(before, after map[string]fileStamp) []string {
+	var changed []string
+	for path, stamp := range after {
+		if old, ok := before[path]; !ok || old != stamp {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
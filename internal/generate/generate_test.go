@@ -0,0 +1,193 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArgs(t *testing.T) {
+	for _, test := range []struct {
+		req  Request
+		want []string
+	}{
+		{Request{Dir: "/p"}, []string{"generate", "."}},
+		{Request{Dir: "/p", Recursive: true}, []string{"generate", "./..."}},
+		{Request{Dir: "/p", File: "gen.go"}, []string{"generate", "gen.go"}},
+		{Request{Dir: "/p", Recursive: true, File: "gen.go"}, []string{"generate", "gen.go"}},
+		{Request{Dir: "/p", Pattern: "mockgen"}, []string{"generate", "-run", "mockgen", "."}},
+		{Request{Dir: "/p", Recursive: true, Pattern: "^stringer"}, []string{"generate", "-run", "^stringer", "./..."}},
+	} {
+		if got := test.req.Args(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v: Args() = %v, want %v", test.req, got, test.want)
+		}
+	}
+}
+
+func TestRunValidatesDir(t *testing.T) {
+	req := Request{Dir: filepath.Join(os.TempDir(), "no-such-generate-dir")}
+	if _, err := req.Run(); err == nil {
+		t.Error("Run with a non-existent directory succeeded, want error")
+	}
+}
+
+// TestRunDirWithSpace runs a real go generate in a directory whose
+// path contains a space; the path must reach the go command unmangled.
+func TestRunDirWithSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gen erate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := "package p\n\n//go:generate go version\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := (&Request{Dir: dir}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Modified) != 0 {
+		t.Errorf("unexpected modified files: %v", res.Modified)
+	}
+}
+
+// TestRunContextCancel cancels a tracked run mid-flight: the go
+// generate subprocess must be killed instead of running to completion.
+func TestRunContextCancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := "package p\n\n//go:generate sleep 30\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var tr Tracker
+	ctx, err := tr.Start(context.Background(), "tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		_, err := (&Request{Dir: dir}).RunContext(ctx)
+		done <- err
+	}()
+	if err := tr.Cancel("tok"); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err == nil {
+		t.Fatal("cancelled run succeeded, want error")
+	}
+	// Well under the directive's 30s sleep, or the cancel did not
+	// reach the subprocess.
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("cancelled run took %v to return", elapsed)
+	}
+}
+
+// TestChangedFiles simulates a generator writing a new file and
+// rewriting an existing one between two snapshots.
+func TestChangedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, contents string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("kept.go", "package p\n")
+	write("rewritten.go", "package p\n")
+
+	before, err := snapshot(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("generated.go", "package p\n\n// Code generated.\n")
+	write("rewritten.go", "package p\n\nvar X = 1\n")
+	after, err := snapshot(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := changedFiles(before, after)
+	want := []string{"generated.go", "rewritten.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changedFiles = %v, want %v", got, want)
+	}
+}
+
+func TestVerboseLogging(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go command not available: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "generatetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "gen.go")
+	if err := ioutil.WriteFile(file, []byte("//go:generate go version\n\npackage p\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var logged []string
+	req := &Request{
+		Dir: dir,
+		Log: func(format string, args ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		},
+	}
+
+	// Non-verbose: informational messages are suppressed.
+	if _, err := req.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("non-verbose run logged %q, want nothing", logged)
+	}
+
+	// Verbose: the run is narrated.
+	req.Verbose = true
+	if _, err := req.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) == 0 {
+		t.Error("verbose run logged nothing")
+	}
+
+	// Failures surface through the error regardless of verbosity.
+	req.Verbose = false
+	logged = nil
+	req.Dir = filepath.Join(dir, "missing")
+	if _, err := req.Run(); err == nil {
+		t.Error("run in a missing directory succeeded, want error")
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	req := Request{Dir: os.TempDir(), Pattern: "("}
+	_, err := req.Run()
+	if err == nil || !strings.Contains(err.Error(), "invalid -run pattern") {
+		t.Errorf("Run with a malformed pattern: error = %v, want pattern rejection", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// A Tracker records in-progress generate runs by token so they can be
+// cancelled from another goroutine.  All state transitions happen
+// under one mutex, and the cancel function itself is invoked outside
+// it, so a token may be cancelled and cleared concurrently without
+// racing.
+type Tracker struct {
+	mu   sync.Mutex
+	runs map[string]*run
+}
+
+// A run is the tracked state of one generate invocation.  A finished
+// run is kept as a tombstone so that a late cancel gets a precise
+// "already finished" answer instead of a not-found error.
+type run struct {
+	cancel   context.CancelFunc
+	finished bool
+}
+
+// Start registers a new run under the token and returns a context
+// that Cancel interrupts.  The token must not already be in use.
+func (t *Tracker) Start(ctx context.Context, token string) (context.Context, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.runs == nil {
+		t.runs = make(map[string]*run)
+	}
+	if r, ok := t.runs[token]; ok && !r.finished {
+		cancel()
+		return nil, fmt.Errorf("a run with token %q is already in progress", token)
+	}
+	t.runs[token] = &run{cancel: cancel}
+	return ctx, nil
+}
+
+// Cancel interrupts the run registered under the token.  It reports
+// whether the token was never started or the run already finished.
+func (t *Tracker) Cancel(token string) error {
+	t.mu.Lock()
+	r, ok := t.runs[token]
+	var cancel context.CancelFunc
+	if ok {
+		cancel, r.cancel = r.cancel, nil
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no run with token %q", token)
+	}
+	if cancel == nil {
+		return fmt.Errorf("run with token %q already finished", token)
+	}
+	cancel()
+	return nil
+}
+
+// Clear marks the run finished, releasing its cancel function.  The
+// token is retained so later cancels are answered meaningfully.
+func (t *Tracker) Clear(token string) {
+	t.mu.Lock()
+	var cancel context.CancelFunc
+	if r, ok := t.runs[token]; ok {
+		cancel, r.cancel = r.cancel, nil
+		r.finished = true
+	}
+	t.mu.Unlock()
+
+	// Release the context's resources; the work is done, so the
+	// cancellation itself is a no-op.
+	if cancel != nil {
+		cancel()
+	}
+}
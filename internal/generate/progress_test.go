@@ -0,0 +1,89 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var got []string
+	w := &ProgressWriter{Report: func(msg string) { got = append(got, msg) }}
+
+	// Partial-line writes must coalesce into one report per line.
+	for _, chunk := range []string{"gener", "ate: running stri", "nger\nsecond line\nand a trail", "ing bit"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"running stringer", "second line"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("reports = %q, want %q", got, want)
+	}
+
+	w.Flush()
+	want = append(want, "and a trailing bit")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after Flush, reports = %q, want %q", got, want)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	var tr Tracker
+
+	ctx, err := tr.Start(context.Background(), "tok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Start(context.Background(), "tok"); err == nil {
+		t.Error("second Start with a live token succeeded, want error")
+	}
+	if err := tr.Cancel("tok"); err != nil {
+		t.Errorf("Cancel = %v", err)
+	}
+	<-ctx.Done()
+	if err := tr.Cancel("tok"); err == nil || !strings.Contains(err.Error(), "already finished") {
+		t.Errorf("second Cancel = %v, want already-finished error", err)
+	}
+	if err := tr.Cancel("other"); err == nil || !strings.Contains(err.Error(), "no run") {
+		t.Errorf("Cancel of unknown token = %v, want not-found error", err)
+	}
+
+	tr.Clear("tok")
+	if err := tr.Cancel("tok"); err == nil || !strings.Contains(err.Error(), "already finished") {
+		t.Errorf("Cancel after Clear = %v, want already-finished error", err)
+	}
+}
+
+// TestTrackerConcurrent races starts, cancels, and clears of the same
+// tokens; run with -race.
+func TestTrackerConcurrent(t *testing.T) {
+	var tr Tracker
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		token := fmt.Sprintf("tok%d", i%4)
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := tr.Start(context.Background(), token); err == nil {
+				tr.Clear(token)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			tr.Cancel(token)
+		}()
+		go func() {
+			defer wg.Done()
+			tr.Clear(token)
+		}()
+	}
+	wg.Wait()
+}
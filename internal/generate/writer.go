@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generate
+
+import (
+	"bytes"
+	"strings"
+)
+
+// A ProgressWriter adapts a command's output stream into per-line
+// progress reports.  Commands deliver partial lines to their stdout
+// and stderr writers, so reporting on every Write produces fragmented,
+// flickering messages; ProgressWriter buffers until a newline and
+// sends exactly one report per complete line, with the "generate: "
+// prefix the go command adds trimmed off for display.
+type ProgressWriter struct {
+	// Report receives each complete output line.
+	Report func(message string)
+
+	buf bytes.Buffer
+}
+
+// Write implements io.Writer.  It never fails; short-lived buffering
+// is the only work done.
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			// Incomplete line: keep it buffered for the next write.
+			w.buf.WriteString(line)
+			break
+		}
+		w.report(line)
+	}
+	return len(p), nil
+}
+
+// Flush reports any buffered partial line.  Callers invoke it once
+// the command has exited, when no completing newline can arrive.
+func (w *ProgressWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.report(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *ProgressWriter) report(line string) {
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimPrefix(line, "generate: ")
+	if line == "" {
+		return
+	}
+	if w.Report != nil {
+		w.Report(line)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mod provides go.mod maintenance helpers for editor
+// integrations: the code lenses to offer on a go.mod file and the
+// commands behind them.
+package mod
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Lens is an action offered on a go.mod file, anchored at the module
+// statement.
+type Lens struct {
+	Title   string   // user-visible label, e.g. "run go mod tidy"
+	Command string   // machine name of the command, e.g. "tidy"
+	Args    []string // arguments to the go command
+	Line    int      // 1-based line of the module statement
+}
+
+// Lenses returns the actions to offer on a go.mod file with the given
+// contents: running go mod tidy, and upgrading all dependencies.  The
+// lenses are anchored at the module statement, or at the first line if
+// none is present.
+func Lenses(modfile []byte) []Lens {
+	line := moduleLine(modfile)
+	return []Lens{
+		{Title: "run go mod tidy", Command: "tidy", Args: []string{"mod", "tidy"}, Line: line},
+		{Title: "upgrade all dependencies", Command: "upgrade.all", Args: []string{"get", "-u", "./..."}, Line: line},
+	}
+}
+
+// moduleLine returns the 1-based line number of the module statement,
+// or 1 if the file has none.
+func moduleLine(modfile []byte) int {
+	scanner := bufio.NewScanner(bytes.NewReader(modfile))
+	for line := 1; scanner.Scan(); line++ {
+		if strings.HasPrefix(strings.TrimSpace(scanner.Text()), "module ") {
+			return line
+		}
+	}
+	return 1
+}
+
+// Run executes the lens's go command in dir, returning an error that
+// includes the command's output if it fails.
+func Run(dir string, lens Lens) error {
+	cmd := exec.Command("go", lens.Args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("go %s: %v\n%s", strings.Join(lens.Args, " "), err, out)
+	}
+	return nil
+}
+
+// DryRun executes the lens's go command against a temporary copy of
+// the module and returns a line diff of the go.mod and go.sum changes
+// it would make, without touching the real files.  An empty diff
+// means the command would change nothing.
+func DryRun(dir string, lens Lens) (string, error) {
+	tmp, err := ioutil.TempDir("", "modsandbox")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyDir(tmp, dir); err != nil {
+		return "", err
+	}
+
+	before, err := snapshotModFiles(tmp)
+	if err != nil {
+		return "", err
+	}
+	if err := Run(tmp, lens); err != nil {
+		return "", err
+	}
+	after, err := snapshotModFiles(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	for _, name := range []string{"go.mod", "go.sum"} {
+		b.WriteString(diffLines(name, before[name], after[name]))
+	}
+	return b.String(), nil
+}
+
+// snapshotModFiles reads the module's metadata files; a missing file
+// is recorded as nil contents.
+func snapshotModFiles(dir string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, name := range []string{"go.mod", "go.sum"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// copyDir recursively copies the module at src into dst, preserving
+// the directory layout.
+func copyDir(dst, src string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			if rel == "." {
+				return nil
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, data, info.Mode().Perm())
+	})
+}
+
+// diffLines renders the changed lines between two versions of a file
+// as a minimal unified-style diff, or "" if they are equal.  Common
+// leading and trailing lines are elided; the middle is shown as
+// removals followed by additions.
+func diffLines(name string, old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	oldLines := strings.Split(string(old), "\n")
+	newLines := strings.Split(string(new), "\n")
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", name, name)
+	for _, line := range oldLines[prefix : len(oldLines)-suffix] {
+		fmt.Fprintf(&b, "-%s\n", line)
+	}
+	for _, line := range newLines[prefix : len(newLines)-suffix] {
+		fmt.Fprintf(&b, "+%s\n", line)
+	}
+	return b.String()
+}
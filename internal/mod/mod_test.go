@@ -0,0 +1,112 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLenses(t *testing.T) {
+	modfile := []byte(`// Deprecated: moved elsewhere.
+
+module example.com/m
+
+require example.com/dep v1.0.0
+`)
+	lenses := Lenses(modfile)
+	if len(lenses) != 2 {
+		t.Fatalf("got %d lenses, want 2", len(lenses))
+	}
+	for _, lens := range lenses {
+		if lens.Line != 3 {
+			t.Errorf("%s lens anchored at line %d, want 3 (the module statement)", lens.Command, lens.Line)
+		}
+	}
+	if got, want := lenses[0].Args, []string{"mod", "tidy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tidy args = %v, want %v", got, want)
+	}
+	if got, want := lenses[1].Args, []string{"get", "-u", "./..."}; !reflect.DeepEqual(got, want) {
+		t.Errorf("upgrade args = %v, want %v", got, want)
+	}
+}
+
+func TestDryRunTidy(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skipf("go command not available: %v", err)
+	}
+	// The test harness may run in GOPATH mode; module commands need
+	// module mode regardless.
+	prev, had := os.LookupEnv("GO111MODULE")
+	os.Setenv("GO111MODULE", "on")
+	defer func() {
+		if had {
+			os.Setenv("GO111MODULE", prev)
+		} else {
+			os.Unsetenv("GO111MODULE")
+		}
+	}()
+
+	dir, err := ioutil.TempDir("", "modtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A go.mod with no go directive: tidy adds one, giving the dry
+	// run a deterministic, network-free change to propose.
+	modfile := "module example.com/m\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(modfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "m.go"), []byte("package m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var tidy Lens
+	for _, lens := range Lenses([]byte(modfile)) {
+		if lens.Command == "tidy" {
+			tidy = lens
+		}
+	}
+
+	diff, err := DryRun(dir, tidy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "--- go.mod") || !strings.Contains(diff, "+go ") {
+		t.Errorf("diff does not propose adding a go directive:\n%s", diff)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != modfile {
+		t.Errorf("DryRun modified the real go.mod:\n%s", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	if diff := diffLines("go.mod", []byte("a\nb\n"), []byte("a\nb\n")); diff != "" {
+		t.Errorf("diff of equal contents = %q, want empty", diff)
+	}
+	diff := diffLines("go.mod", []byte("a\nb\nc\n"), []byte("a\nx\nc\n"))
+	want := "--- go.mod\n+++ go.mod\n-b\n+x\n"
+	if diff != want {
+		t.Errorf("diff = %q, want %q", diff, want)
+	}
+}
+
+func TestModuleLineMissing(t *testing.T) {
+	if got := moduleLine([]byte("require example.com/dep v1.0.0\n")); got != 1 {
+		t.Errorf("moduleLine = %d, want 1 for a file with no module statement", got)
+	}
+}
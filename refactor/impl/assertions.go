@@ -0,0 +1,352 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// An Assertion describes an interface-satisfaction assertion of the
+// form
+//
+//	var _ I = (*T)(nil)
+//
+// found in a package.  Editors can surface an "implement missing
+// methods" action on such lines; Missing reports whether there is
+// anything to do.
+type Assertion struct {
+	Pos token.Position // position of the var spec
+
+	// IfacePath and IfaceName identify the asserted interface.
+	IfacePath string
+	IfaceName string
+
+	// IfaceTypeArgs lists the type arguments the assertion instantiates
+	// a generic interface with, e.g. ["Event"] for
+	//
+	//	var _ Handler[Event] = (*Processor)(nil)
+	//
+	// spelled relative to the package, ready for a Request's
+	// IfaceTypeArgs.  It is nil for ordinary interfaces.
+	IfaceTypeArgs []string
+
+	// Target is the concrete type's base name, e.g. "*T", suitable
+	// for a Request's Target field.  For a generic concrete type the
+	// instantiation arguments are not part of the target — methods
+	// are declared on the base type — and are collected in TypeArgs
+	// instead.
+	Target string
+
+	// ConcretePath is the import path of the package declaring the
+	// concrete type.  It usually matches the scanned package, but an
+	// assertion may be made against a foreign type, as in
+	//
+	//	var _ iface.Runner = other.New()
+	//
+	// and stubs can only be added where the type is declared.
+	ConcretePath string
+
+	// Pointer reports whether the assertion was made against the
+	// pointer type, so clients need not parse Target to choose between
+	// pointer and value receivers.
+	Pointer bool
+
+	// TypeArgs lists the type arguments the assertion instantiates a
+	// generic concrete type with, e.g. ["int", "string"] for
+	// (*T[int, string])(nil).  It is nil for ordinary types.
+	TypeArgs []string
+
+	// Missing reports whether the concrete type currently fails to
+	// implement the interface.
+	Missing bool
+
+	// Speculative reports whether the assertion was inferred from a
+	// type assertion or type-switch case.  Those ask a run-time
+	// question — the program is valid whether or not the answer is
+	// yes — so unlike a var spec, assignment, or return they impose
+	// no compile-time obligation, and save-time fixes must leave
+	// them alone.
+	Speculative bool
+}
+
+// FindAssertions scans the package for var _ I = ... satisfaction
+// assertions whose asserted type is an interface, resolving each to
+// the interface and concrete type involved.  The package is loaded
+// with type errors tolerated, since an assertion that does not yet
+// compile is precisely the interesting case.
+func FindAssertions(ctxt *build.Context, pkgPath string) ([]*Assertion, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(pkgPath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+	info := prog.Package(pkgPath)
+
+	var assertions []*Assertion
+	addAssertion := func(pos token.Pos, named *types.Named, iface *types.Interface, value ast.Expr, speculative bool) {
+		target, args, ctyp := concreteTarget(info.Pkg, &info.Info, value)
+		if target == "" {
+			return
+		}
+		var ifaceArgs []string
+		if targs := named.TypeArgs(); targs != nil {
+			for i := 0; i < targs.Len(); i++ {
+				ifaceArgs = append(ifaceArgs, types.TypeString(targs.At(i), types.RelativeTo(info.Pkg)))
+			}
+		}
+		base := ctyp
+		if ptr, ok := base.(*types.Pointer); ok {
+			base = ptr.Elem()
+		}
+		var cpath string
+		if named, ok := base.(*types.Named); ok && named.Obj().Pkg() != nil {
+			cpath = named.Obj().Pkg().Path()
+		}
+		assertions = append(assertions, &Assertion{
+			Pos:           prog.Fset.Position(pos),
+			IfacePath:     named.Obj().Pkg().Path(),
+			IfaceName:     named.Obj().Name(),
+			IfaceTypeArgs: ifaceArgs,
+			Target:        target,
+			ConcretePath:  cpath,
+			Pointer:       strings.HasPrefix(target, "*"),
+			TypeArgs:      args,
+			Missing:       !types.Implements(ctyp, iface),
+			Speculative:   speculative,
+		})
+	}
+	for _, f := range info.Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				if len(vs.Names) != 1 || vs.Names[0].Name != "_" || vs.Type == nil || len(vs.Values) != 1 {
+					continue
+				}
+				named, ok := info.Info.TypeOf(vs.Type).(*types.Named)
+				if !ok || named.Obj().Pkg() == nil {
+					continue
+				}
+				iface, ok := named.Underlying().(*types.Interface)
+				if !ok {
+					continue
+				}
+				addAssertion(vs.Pos(), named, iface, vs.Values[0], false)
+			}
+		}
+		// A return statement in a function whose result is an
+		// interface type asserts satisfaction just as an assignment
+		// does.  The returned expression's type comes from the type
+		// info, so a generic constructor call such as New[int]()
+		// resolves to the instantiated concrete type rather than a
+		// type parameter.
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Type.Results == nil || fn.Body == nil {
+				continue
+			}
+			var results []ast.Expr
+			for _, field := range fn.Type.Results.List {
+				n := len(field.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					results = append(results, field.Type)
+				}
+			}
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				if _, ok := n.(*ast.FuncLit); ok {
+					return false // a literal's returns are its own
+				}
+				ret, ok := n.(*ast.ReturnStmt)
+				if !ok || len(ret.Results) != len(results) {
+					return true
+				}
+				for i, res := range results {
+					named, ok := info.Info.TypeOf(res).(*types.Named)
+					if !ok || named.Obj().Pkg() == nil {
+						continue
+					}
+					iface, ok := named.Underlying().(*types.Interface)
+					if !ok {
+						continue
+					}
+					addAssertion(ret.Pos(), named, iface, ret.Results[i], false)
+				}
+				return true
+			})
+		}
+
+		// Assignments to a variable of interface type, such as
+		//
+		//	var x I
+		//	x = &T{}
+		//
+		// assert satisfaction just as a blank var spec does.
+		ast.Inspect(f, func(n ast.Node) bool {
+			as, ok := n.(*ast.AssignStmt)
+			if !ok || as.Tok != token.ASSIGN || len(as.Lhs) != len(as.Rhs) {
+				return true
+			}
+			for i, lhs := range as.Lhs {
+				named, ok := info.Info.TypeOf(lhs).(*types.Named)
+				if !ok || named.Obj().Pkg() == nil {
+					continue
+				}
+				iface, ok := named.Underlying().(*types.Interface)
+				if !ok {
+					continue
+				}
+				addAssertion(as.Pos(), named, iface, as.Rhs[i], false)
+			}
+			return true
+		})
+		// A type assertion x.(I), or a type-switch case naming an
+		// interface, says the values flowing into x are meant to
+		// implement it; the concrete types assigned to the scrutinee
+		// elsewhere in the file are the candidates.
+		ast.Inspect(f, func(n ast.Node) bool {
+			check := func(pos token.Pos, scrutinee, typ ast.Expr) {
+				named, ok := info.Info.TypeOf(typ).(*types.Named)
+				if !ok || named.Obj().Pkg() == nil {
+					return
+				}
+				iface, ok := named.Underlying().(*types.Interface)
+				if !ok {
+					return
+				}
+				for _, value := range scrutineeSources(&info.Info, f, scrutinee) {
+					addAssertion(pos, named, iface, value, true)
+				}
+			}
+			switch n := n.(type) {
+			case *ast.TypeAssertExpr:
+				// Type is nil for the x.(type) of a switch guard, which
+				// asserts nothing by itself.
+				if n.Type != nil {
+					check(n.Pos(), n.X, n.Type)
+				}
+			case *ast.TypeSwitchStmt:
+				var scrutinee ast.Expr
+				switch stmt := n.Assign.(type) {
+				case *ast.AssignStmt:
+					if len(stmt.Rhs) == 1 {
+						if ta, ok := stmt.Rhs[0].(*ast.TypeAssertExpr); ok {
+							scrutinee = ta.X
+						}
+					}
+				case *ast.ExprStmt:
+					if ta, ok := stmt.X.(*ast.TypeAssertExpr); ok {
+						scrutinee = ta.X
+					}
+				}
+				if scrutinee == nil {
+					return true
+				}
+				for _, clause := range n.Body.List {
+					cc, ok := clause.(*ast.CaseClause)
+					if !ok {
+						continue
+					}
+					for _, t := range cc.List {
+						check(cc.Pos(), scrutinee, t)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return assertions, nil
+}
+
+// scrutineeSources returns the expressions assigned to the asserted
+// variable anywhere in the file, so a type assertion's scrutinee can be
+// traced back to the concrete types that flow into it.  Only a plain
+// identifier can be traced; an assertion on a call result or selector
+// yields nothing.
+func scrutineeSources(info *types.Info, f *ast.File, x ast.Expr) []ast.Expr {
+	id, ok := x.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := info.Uses[id]
+	if obj == nil {
+		return nil
+	}
+	var sources []ast.Expr
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) != len(n.Rhs) {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				lid, ok := lhs.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if info.Uses[lid] == obj || info.Defs[lid] == obj {
+					sources = append(sources, n.Rhs[i])
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				if info.Defs[name] == obj && i < len(n.Values) {
+					sources = append(sources, n.Values[i])
+				}
+			}
+		}
+		return true
+	})
+	return sources
+}
+
+// concreteTarget resolves the concrete type of an assertion's value
+// expression, such as (*T)(nil), &T{}, or T{}.  It returns the base
+// type as written ("*T" or "T") and, for an instantiated generic type
+// such as (*T[int, string])(nil), the type arguments, along with the
+// type itself.  It returns "" if the value does not denote a named
+// concrete type.
+func concreteTarget(pkg *types.Package, info *types.Info, x ast.Expr) (string, []string, types.Type) {
+	t := info.TypeOf(x)
+	target := ""
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+		target = "*"
+	}
+	named, ok := t.(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return "", nil, nil
+	}
+	target += named.Obj().Name()
+	var args []string
+	if targs := named.TypeArgs(); targs != nil {
+		for i := 0; i < targs.Len(); i++ {
+			args = append(args, types.TypeString(targs.At(i), types.RelativeTo(pkg)))
+		}
+	}
+	if target[0] == '*' {
+		return target, args, types.NewPointer(named)
+	}
+	return target, args, named
+}
@@ -0,0 +1,289 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindAssertionsTypeSwitch(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Handler interface {
+	Handle() error
+}
+
+type Job struct{}
+
+func dispatch() {
+	var x interface{}
+	x = &Job{}
+	switch v := x.(type) {
+	case Handler:
+		_ = v
+	}
+}
+
+func probe() {
+	var y interface{}
+	y = Job{}
+	_, _ = y.(Handler)
+}`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 2 {
+		t.Fatalf("got %d assertions, want 2: %+v", len(assertions), assertions)
+	}
+	for _, a := range assertions {
+		if a.IfaceName != "Handler" || !a.Missing {
+			t.Errorf("assertion = %+v, want missing Handler", a)
+		}
+		if !a.Speculative {
+			t.Errorf("assertion at %v not marked speculative", a.Pos)
+		}
+	}
+	if assertions[0].Target != "*Job" {
+		t.Errorf("type-switch target = %q, want *Job", assertions[0].Target)
+	}
+	if assertions[1].Target != "Job" {
+		t.Errorf("assertion target = %q, want Job", assertions[1].Target)
+	}
+}
+
+func TestFindAssertions(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type I interface {
+	M()
+}
+
+// Done implements I; its assertion compiles.
+type Done struct{}
+
+func (d *Done) M() {}
+
+// Todo does not implement I yet.
+type Todo struct{}
+
+var (
+	_ I = (*Done)(nil)
+	_ I = (*Todo)(nil)
+	_ I = &Done{}
+)
+
+var notBlank I = &Done{} // named variable: not an assertion`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 3 {
+		t.Fatalf("got %d assertions, want 3: %+v", len(assertions), assertions)
+	}
+	for _, a := range assertions {
+		if a.IfacePath != "conc" || a.IfaceName != "I" {
+			t.Errorf("assertion resolved to %s.%s, want conc.I", a.IfacePath, a.IfaceName)
+		}
+		wantMissing := a.Target == "*Todo"
+		if a.Missing != wantMissing {
+			t.Errorf("assertion for %s: Missing = %v, want %v", a.Target, a.Missing, wantMissing)
+		}
+		if a.Speculative {
+			t.Errorf("var-spec assertion for %s marked speculative", a.Target)
+		}
+	}
+}
+
+func TestFindAssertionsAssignment(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type I interface {
+	M()
+}
+
+type Todo struct{}
+
+func wire() {
+	var x I
+	x = &Todo{}
+	_ = x
+}`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 1 {
+		t.Fatalf("got %d assertions, want 1: %+v", len(assertions), assertions)
+	}
+	a := assertions[0]
+	if a.Target != "*Todo" || a.IfaceName != "I" || !a.Missing {
+		t.Errorf("assertion = %+v, want missing conc.I for *Todo", a)
+	}
+	if a.Pos.Line != 11 {
+		t.Errorf("assertion at line %d, want 11 (the assignment)", a.Pos.Line)
+	}
+}
+
+// TestFindAssertionsForeignType checks that an assertion against a type
+// declared in another package records that package, so clients know the
+// stubs cannot go in the scanned one.
+func TestFindAssertionsForeignType(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"other": {`package other
+
+type Thing struct{}
+
+func New() *Thing { return &Thing{} }`},
+		"conc": {`package conc
+
+import (
+	"iface"
+	"other"
+)
+
+var _ iface.Runner = other.New()`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 1 {
+		t.Fatalf("got %d assertions, want 1: %+v", len(assertions), assertions)
+	}
+	a := assertions[0]
+	if a.Target != "*Thing" || !a.Missing {
+		t.Errorf("assertion = %+v, want missing *Thing", a)
+	}
+	if a.ConcretePath != "other" {
+		t.Errorf("ConcretePath = %q, want %q", a.ConcretePath, "other")
+	}
+}
+
+func TestFindAssertionsGeneric(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Pair[A, B any] interface {
+	First() A
+	Second() B
+}
+
+type Tuple[A, B any] struct {
+	a A
+	b B
+}
+
+var _ Pair[int, string] = (*Tuple[int, string])(nil)`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 1 {
+		t.Fatalf("got %d assertions, want 1: %+v", len(assertions), assertions)
+	}
+	a := assertions[0]
+	if a.Target != "*Tuple" {
+		t.Errorf("Target = %q, want %q (the base type, not a type argument)", a.Target, "*Tuple")
+	}
+	if want := []string{"int", "string"}; !reflect.DeepEqual(a.TypeArgs, want) {
+		t.Errorf("TypeArgs = %q, want %q", a.TypeArgs, want)
+	}
+	if a.IfaceName != "Pair" || !a.Missing {
+		t.Errorf("assertion = %+v, want missing conc.Pair", a)
+	}
+}
+
+func TestFindAssertionsPointer(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Val struct{}
+
+type Ptr struct{}
+
+var _ iface.Runner = Val{}
+var _ iface.Runner = &Ptr{}`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTarget := make(map[string]bool)
+	for _, a := range assertions {
+		byTarget[a.Target] = a.Pointer
+	}
+	if ptr, ok := byTarget["Val"]; !ok || ptr {
+		t.Errorf("Val: Pointer = %v, %v; want false, true", ptr, ok)
+	}
+	if ptr, ok := byTarget["*Ptr"]; !ok || !ptr {
+		t.Errorf("*Ptr: Pointer = %v, %v; want true, true", ptr, ok)
+	}
+}
+
+// TestFindAssertionsReturn resolves the concrete type of a returned
+// expression through the type info, so a generic constructor call
+// yields the instantiated type rather than a type parameter.
+func TestFindAssertionsReturn(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Box[T any] struct{}
+
+func New[T any]() *Box[T] { return &Box[T]{} }
+
+func Make() iface.Runner {
+	return New[int]()
+}`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 1 {
+		t.Fatalf("got %d assertions %+v, want 1", len(assertions), assertions)
+	}
+	a := assertions[0]
+	if a.IfaceName != "Runner" || a.Target != "*Box" || !a.Missing {
+		t.Errorf("assertion = %+v, want missing Runner on *Box", a)
+	}
+	if len(a.TypeArgs) != 1 || a.TypeArgs[0] != "int" {
+		t.Errorf("TypeArgs = %v, want [int]", a.TypeArgs)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// A Candidate is an interface a concrete type could implement, along
+// with how many of its methods the type still lacks.
+type Candidate struct {
+	Path    string // import path of the declaring package
+	Name    string // the interface's name
+	Missing int    // methods the concrete type does not yet have
+}
+
+// CandidateInterfaces returns the interfaces among the known packages
+// that the named concrete type partially or fully satisfies, ranked by
+// how few methods it is missing.  Editors use it to offer "which
+// interface shall this type implement?" without requiring the user to
+// write an assertion first; selecting a candidate feeds straight into a
+// Request.
+//
+// Interfaces the type shares no methods with are omitted, as are
+// empty interfaces, which everything satisfies vacuously.  scope, if
+// non-empty, restricts the scan to import paths with that prefix, so
+// large GOPATHs can be capped to the workspace.  The scan honors ctx
+// and returns ctx.Err() once it is cancelled.
+func CandidateInterfaces(ctx context.Context, ctxt *build.Context, concretePath, typeName, scope string) ([]*Candidate, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	paths, err := KnownPackages(ctx, ctxt, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Broken packages elsewhere in the workspace should not block the
+	// query; load permissively.
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(concretePath)
+	for _, path := range paths {
+		if scope == "" || path == scope || strings.HasPrefix(path, scope+"/") {
+			conf.Import(path)
+		}
+	}
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := prog.Package(concretePath).Pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type named %s in package %q", typeName, concretePath)
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok || types.IsInterface(named) {
+		return nil, fmt.Errorf("%s is not a concrete named type", typeName)
+	}
+	recv := types.NewPointer(named)
+
+	var candidates []*Candidate
+	for _, info := range prog.Imported {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pkgScope := info.Pkg.Scope()
+		for _, name := range pkgScope.Names() {
+			iobj, ok := pkgScope.Lookup(name).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			iface, ok := iobj.Type().Underlying().(*types.Interface)
+			if !ok || iface.NumMethods() == 0 {
+				continue
+			}
+			missing := 0
+			for i := 0; i < iface.NumMethods(); i++ {
+				m := iface.Method(i)
+				f, _, _ := types.LookupFieldOrMethod(recv, true, info.Pkg, m.Name())
+				if _, ok := f.(*types.Func); !ok {
+					missing++
+				}
+			}
+			if missing == iface.NumMethods() {
+				continue // nothing in common; not a useful suggestion
+			}
+			candidates = append(candidates, &Candidate{
+				Path:    info.Pkg.Path(),
+				Name:    name,
+				Missing: missing,
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Missing != b.Missing {
+			return a.Missing < b.Missing
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Name < b.Name
+	})
+	return candidates, nil
+}
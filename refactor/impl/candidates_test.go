@@ -0,0 +1,42 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCandidateInterfaces(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type ReadCloser interface {
+	Read(p []byte) (int, error)
+	Close() error
+}
+
+type Unrelated interface {
+	Frobnicate()
+}`},
+		"conc": {`package conc
+
+type File struct{}
+
+func (f *File) Close() error { return nil }`},
+	})
+
+	got, err := CandidateInterfaces(context.Background(), ctxt, "conc", "File", "iface")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d candidates %+v, want 1", len(got), got)
+	}
+	c := got[0]
+	if c.Path != "iface" || c.Name != "ReadCloser" || c.Missing != 1 {
+		t.Errorf("candidate = %+v, want iface.ReadCloser missing 1", c)
+	}
+}
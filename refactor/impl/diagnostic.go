@@ -0,0 +1,133 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/build"
+	"go/types"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Compiler diagnostics for interface satisfaction look like
+//
+//	cannot use c (type *Clock) as type iface.Waiter in assignment:
+//		*Clock does not implement iface.Waiter (missing Wait method)
+//
+// with "in argument to f" and similar variants for other contexts.
+var (
+	diagUseRx     = regexp.MustCompile(`cannot use .* \(type ([^)]+)\) as type ([^ ]+) in `)
+	diagMissingRx = regexp.MustCompile(`\(missing (\w+) method\)`)
+)
+
+// parseDiagnostic extracts the concrete type, interface type, and
+// missing method name from an interface-satisfaction diagnostic.  The
+// type names are as the compiler printed them, possibly qualified and
+// with a leading "*".
+func parseDiagnostic(msg string) (concrete, iface, method string, ok bool) {
+	m := diagUseRx.FindStringSubmatch(msg)
+	if m == nil {
+		return "", "", "", false
+	}
+	missing := diagMissingRx.FindStringSubmatch(msg)
+	if missing == nil {
+		return "", "", "", false
+	}
+	return m[1], m[2], missing[1], true
+}
+
+// RequestFromDiagnostic builds a stub-generation Request from a
+// compiler diagnostic about interface satisfaction, for callers that
+// could not recover the types from the syntax around the diagnostic
+// and have only its text and the import path of the package it was
+// reported in.  The printed names are resolved through that package's
+// scope and imports.  Diagnostics are free-form text, so everything is
+// best-effort: RequestFromDiagnostic returns nil if the message does
+// not match or a name does not resolve.
+func RequestFromDiagnostic(ctxt *build.Context, concretePath, msg string) *Request {
+	concrete, ifaceStr, methodName, ok := parseDiagnostic(msg)
+	if !ok {
+		return nil
+	}
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+
+	// The package contains a type error by definition, so load
+	// permissively.
+	conf := loader.Config{Build: ctxt, AllowErrors: true}
+	conf.TypeChecker.Error = func(error) {}
+	conf.Import(concretePath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil
+	}
+	pkg := prog.Package(concretePath)
+
+	ptr := strings.HasPrefix(concrete, "*")
+	tname := strings.TrimPrefix(concrete, "*")
+	if i := strings.LastIndex(tname, "."); i >= 0 {
+		tname = tname[i+1:]
+	}
+	obj, ok := pkg.Pkg.Scope().Lookup(tname).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+
+	ipath, iname := concretePath, ifaceStr
+	if i := strings.LastIndex(ifaceStr, "."); i >= 0 {
+		iname = ifaceStr[i+1:]
+		ipath = ""
+		for _, imp := range pkg.Pkg.Imports() {
+			if imp.Name() == ifaceStr[:i] {
+				ipath = imp.Path()
+				break
+			}
+		}
+		if ipath == "" {
+			return nil
+		}
+	}
+	ipkg := prog.Package(ipath)
+	if ipkg == nil {
+		return nil
+	}
+	iobj, ok := ipkg.Pkg.Scope().Lookup(iname).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	iface, ok := iobj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil
+	}
+	found := false
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == methodName {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	recv := strings.ToLower(tname[:1]) + " "
+	if ptr {
+		recv += "*"
+	}
+	recv += tname
+
+	return &Request{
+		Ctxt:         ctxt,
+		IfacePath:    ipath,
+		IfaceName:    iname,
+		ConcretePath: concretePath,
+		File:         filepath.Base(prog.Fset.Position(obj.Pos()).Filename),
+		Target:       recv,
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestFromDiagnostic(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Waiter interface {
+	Wait() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Clock struct{}
+
+var _ iface.Waiter
+
+type Local interface {
+	Tick() int
+}
+
+type Timer struct{}`},
+	})
+
+	const msg = `cannot use &Clock{} (type *Clock) as type iface.Waiter in assignment:
+	*Clock does not implement iface.Waiter (missing Wait method)`
+	req := RequestFromDiagnostic(ctxt, "conc", msg)
+	if req == nil {
+		t.Fatalf("RequestFromDiagnostic(%q) = nil", msg)
+	}
+	if req.IfacePath != "iface" || req.IfaceName != "Waiter" {
+		t.Errorf("interface = %s.%s, want iface.Waiter", req.IfacePath, req.IfaceName)
+	}
+	if req.Target != "c *Clock" {
+		t.Errorf("target = %q, want %q", req.Target, "c *Clock")
+	}
+	if req.File != "0.go" {
+		t.Errorf("file = %q, want 0.go", req.File)
+	}
+
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.Methods), "func (c *Clock) Wait() error {") {
+		t.Errorf("stubs missing Wait:\n%s", resp.Methods)
+	}
+
+	// An unqualified interface resolves within the package itself.
+	req = RequestFromDiagnostic(ctxt, "conc", `cannot use t (type Timer) as type Local in argument to run:
+	Timer does not implement Local (missing Tick method)`)
+	if req == nil {
+		t.Fatal("RequestFromDiagnostic returned nil for a same-package interface")
+	}
+	if req.IfacePath != "conc" || req.IfaceName != "Local" || req.Target != "t Timer" {
+		t.Errorf("got %s.%s target %q, want conc.Local target %q", req.IfacePath, req.IfaceName, req.Target, "t Timer")
+	}
+
+	// Messages that do not describe a missing interface method fall
+	// back to nil rather than guessing.
+	for _, msg := range []string{
+		"undefined: Clock",
+		"cannot use x (type int) as type string in assignment",
+		`cannot use x (type *Gone) as type iface.Waiter in assignment:
+	*Gone does not implement iface.Waiter (missing Wait method)`,
+		`cannot use x (type *Clock) as type other.Waiter in assignment:
+	*Clock does not implement other.Waiter (missing Wait method)`,
+	} {
+		if req := RequestFromDiagnostic(ctxt, "conc", msg); req != nil {
+			t.Errorf("RequestFromDiagnostic(%q) = %+v, want nil", msg, req)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// A MethodDiff describes one method's difference between two
+// interfaces' method sets.
+type MethodDiff struct {
+	Name string
+	Old  string // the method's signature in the old interface, or "" when added
+	New  string // the method's signature in the new interface, or "" when removed
+}
+
+// InterfaceDiff compares the complete method sets of two interfaces —
+// typically an old and a new version of the same one — and reports
+// which methods were added, removed, and changed in signature.  The
+// result shows the blast radius of an interface change before
+// implementers are brought up to date.  Embedded methods are included,
+// since they bind implementers just the same; signatures are rendered
+// relative to their declaring package.  Each slice is sorted by method
+// name.
+func InterfaceDiff(ctxt *build.Context, oldPath, oldName, newPath, newName string) (added, removed, changed []*MethodDiff, err error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	conf := loader.Config{
+		Build:       ctxt,
+		ParserMode:  parser.ParseComments,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(oldPath)
+	conf.Import(newPath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	oldIface := lookupInterface(prog, oldPath, oldName)
+	if oldIface == nil {
+		return nil, nil, nil, fmt.Errorf("no interface %s in package %q", oldName, oldPath)
+	}
+	newIface := lookupInterface(prog, newPath, newName)
+	if newIface == nil {
+		return nil, nil, nil, fmt.Errorf("no interface %s in package %q", newName, newPath)
+	}
+
+	render := func(f *types.Func) string {
+		qual := types.RelativeTo(f.Pkg())
+		return f.Name() + strings.TrimPrefix(types.TypeString(f.Type(), qual), "func")
+	}
+	oldMethods := make(map[string]*types.Func, oldIface.NumMethods())
+	for i := 0; i < oldIface.NumMethods(); i++ {
+		m := oldIface.Method(i)
+		oldMethods[m.Name()] = m
+	}
+	for i := 0; i < newIface.NumMethods(); i++ {
+		m := newIface.Method(i)
+		old, ok := oldMethods[m.Name()]
+		switch {
+		case !ok:
+			added = append(added, &MethodDiff{Name: m.Name(), New: render(m)})
+		case !types.Identical(old.Type(), m.Type()):
+			changed = append(changed, &MethodDiff{Name: m.Name(), Old: render(old), New: render(m)})
+		}
+		delete(oldMethods, m.Name())
+	}
+	for _, old := range oldMethods {
+		removed = append(removed, &MethodDiff{Name: old.Name(), Old: render(old)})
+	}
+	byName := func(diffs []*MethodDiff) {
+		sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	}
+	byName(added)
+	byName(removed)
+	byName(changed)
+	return added, removed, changed, nil
+}
@@ -0,0 +1,46 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"testing"
+)
+
+func TestInterfaceDiff(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"old": {`package old
+
+type Runner interface {
+	Run() error
+	Stop()
+	Pause()
+}`},
+		"new": {`package new
+
+type Runner interface {
+	Run(count int) error
+	Stop()
+	Start() error
+}`},
+	})
+
+	added, removed, changed, err := InterfaceDiff(ctxt, "old", "Runner", "new", "Runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0].Name != "Start" || added[0].New != "Start() error" {
+		t.Errorf("added = %v, want Start() error", added)
+	}
+	if len(removed) != 1 || removed[0].Name != "Pause" || removed[0].Old != "Pause()" {
+		t.Errorf("removed = %v, want Pause()", removed)
+	}
+	if len(changed) != 1 || changed[0].Name != "Run" {
+		t.Fatalf("changed = %v, want Run", changed)
+	}
+	if changed[0].Old != "Run() error" || changed[0].New != "Run(count int) error" {
+		t.Errorf("changed Run = %q -> %q, want Run() error -> Run(count int) error",
+			changed[0].Old, changed[0].New)
+	}
+}
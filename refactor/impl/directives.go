@@ -0,0 +1,129 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// An ImplDirective is an //impl: annotation on a type declaration,
+//
+//	//impl:io.Writer
+//	type Buf struct{ ... }
+//
+// declaring in source control which interface the type is intended to
+// implement.  Editors can offer an "implement io.Writer" action on the
+// annotated declaration, and a batch run can stub every annotated type
+// in the package; the fields map directly onto a Request.
+type ImplDirective struct {
+	Pos token.Position // position of the directive comment
+
+	// IfacePath and IfaceName identify the declared interface.
+	IfacePath string
+	IfaceName string
+
+	// Target is the annotated type with a pointer receiver, e.g. "*T",
+	// suitable for a Request's Target field.
+	Target string
+
+	// Missing reports whether the type currently fails to implement
+	// the interface.
+	Missing bool
+}
+
+// FindImplDirectives scans the package for //impl: directives attached
+// to type declarations.  A directive that names an unresolvable
+// interface is skipped rather than reported as an error, since the
+// annotation may refer to a package not yet imported anywhere; the
+// package itself is loaded with type errors tolerated.
+func FindImplDirectives(ctxt *build.Context, pkgPath string) ([]*ImplDirective, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	conf := loader.Config{
+		Build:       ctxt,
+		ParserMode:  parser.ParseComments,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(pkgPath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+	info := prog.Package(pkgPath)
+
+	var directives []*ImplDirective
+	addDirective := func(doc *ast.CommentGroup, name string) {
+		if doc == nil {
+			return
+		}
+		obj, _ := info.Pkg.Scope().Lookup(name).(*types.TypeName)
+		for _, c := range doc.List {
+			if !strings.HasPrefix(c.Text, "//impl:") {
+				continue
+			}
+			iface := strings.TrimSpace(strings.TrimPrefix(c.Text, "//impl:"))
+			dot := strings.LastIndex(iface, ".")
+			if dot < 0 {
+				continue
+			}
+			d := &ImplDirective{
+				Pos:       prog.Fset.Position(c.Pos()),
+				IfacePath: iface[:dot],
+				IfaceName: iface[dot+1:],
+				Target:    "*" + name,
+				Missing:   true,
+			}
+			if obj != nil {
+				if it := lookupInterface(prog, d.IfacePath, d.IfaceName); it != nil {
+					d.Missing = !types.Implements(types.NewPointer(obj.Type()), it)
+				}
+			}
+			directives = append(directives, d)
+		}
+	}
+	for _, f := range info.Files {
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				// A single-spec declaration's comment is attached to
+				// the GenDecl; grouped specs carry their own.
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				addDirective(doc, ts.Name.Name)
+			}
+		}
+	}
+	return directives, nil
+}
+
+// lookupInterface resolves the named interface among the packages the
+// program has loaded, or nil if it is not there.
+func lookupInterface(prog *loader.Program, path, name string) *types.Interface {
+	info := prog.Package(path)
+	if info == nil {
+		return nil
+	}
+	obj, ok := info.Pkg.Scope().Lookup(name).(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	iface, _ := obj.Type().Underlying().(*types.Interface)
+	return iface
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import "testing"
+
+func TestFindImplDirectives(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+import _ "iface"
+
+//impl:iface.Runner
+type Job struct{}
+
+// Done already implements the interface.
+//impl:iface.Runner
+type Done struct{}
+
+func (d *Done) Run() error { return nil }
+
+// Plain has no directive.
+type Plain struct{}`},
+	})
+
+	got, err := FindImplDirectives(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d directives %+v, want 2", len(got), got)
+	}
+	d := got[0]
+	if d.IfacePath != "iface" || d.IfaceName != "Runner" || d.Target != "*Job" || !d.Missing {
+		t.Errorf("directives[0] = %+v, want missing iface.Runner on *Job", d)
+	}
+	if d := got[1]; d.Target != "*Done" || d.Missing {
+		t.Errorf("directives[1] = %+v, want satisfied directive on *Done", d)
+	}
+}
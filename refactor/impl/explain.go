@@ -0,0 +1,137 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// resolveTypes loads the request's packages and resolves the interface
+// and the concrete type.  T is the concrete type with any pointer from
+// the target applied.
+func resolveTypes(req *Request) (iobj *types.TypeName, iface *types.Interface, cobj *types.TypeName, T types.Type, err error) {
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	conf := loader.Config{Build: ctxt}
+	conf.Import(req.IfacePath)
+	conf.Import(req.ConcretePath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	iobj, ok := prog.Package(req.IfacePath).Pkg.Scope().Lookup(req.IfaceName).(*types.TypeName)
+	if !ok {
+		return nil, nil, nil, nil, fmt.Errorf("no interface named %s in package %q", req.IfaceName, req.IfacePath)
+	}
+	iface, ok = iobj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, nil, nil, nil, fmt.Errorf("%s is not an interface", req.IfaceName)
+	}
+
+	_, recvType, err := parseTarget(req.Target)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	tname := strings.TrimPrefix(recvType, "*")
+	cobj, ok = prog.Package(req.ConcretePath).Pkg.Scope().Lookup(tname).(*types.TypeName)
+	if !ok {
+		return nil, nil, nil, nil, fmt.Errorf("no type named %s in package %q", tname, req.ConcretePath)
+	}
+	T = cobj.Type()
+	if strings.HasPrefix(recvType, "*") {
+		T = types.NewPointer(T)
+	}
+	return iobj, iface, cobj, T, nil
+}
+
+// Explain reports, in human-readable form, why the request's concrete
+// type does not implement its interface: which methods are missing,
+// which have the wrong signature (with have/want details), and when a
+// method exists but only on the pointer type.  It returns "" if the
+// type does implement the interface.  Only the request's package and
+// type fields are consulted.
+func Explain(req *Request) (string, error) {
+	iobj, iface, cobj, T, err := resolveTypes(req)
+	if err != nil {
+		return "", err
+	}
+	concretePkg := cobj.Pkg()
+
+	if types.Implements(T, iface) {
+		return "", nil
+	}
+
+	qual := types.RelativeTo(concretePkg)
+	sigString := func(name string, t types.Type) string {
+		return methodSignature(name, t, qual)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s does not implement %s:\n", types.TypeString(T, qual), iobj.Name())
+	valueSet := types.NewMethodSet(T)
+	ptrSet := types.NewMethodSet(types.NewPointer(cobj.Type()))
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		if sel := valueSet.Lookup(m.Pkg(), m.Name()); sel != nil {
+			if f := sel.Obj().(*types.Func); !types.Identical(f.Type(), m.Type()) {
+				fmt.Fprintf(&b, "\twrong signature for method %s:\n\t\thave %s\n\t\twant %s\n",
+					m.Name(), sigString(m.Name(), f.Type()), sigString(m.Name(), m.Type()))
+			}
+			continue
+		}
+		if sel := ptrSet.Lookup(m.Pkg(), m.Name()); sel != nil {
+			if f := sel.Obj().(*types.Func); types.Identical(f.Type(), m.Type()) {
+				fmt.Fprintf(&b, "\tmethod %s has a pointer receiver\n", m.Name())
+			} else {
+				fmt.Fprintf(&b, "\twrong signature for method %s:\n\t\thave %s\n\t\twant %s\n",
+					m.Name(), sigString(m.Name(), f.Type()), sigString(m.Name(), m.Type()))
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "\tmissing method %s\n", sigString(m.Name(), m.Type()))
+	}
+	return b.String(), nil
+}
+
+// methodSignature renders a method's name and signature relative to
+// the given qualifier, e.g. "Read(p []byte) (int, error)".
+func methodSignature(name string, t types.Type, qual types.Qualifier) string {
+	return name + strings.TrimPrefix(types.TypeString(t, qual), "func")
+}
+
+// HoverContent returns markdown suitable for a hover on an
+// interface-satisfaction assertion such as var _ I = (*T)(nil): the
+// interface's full method set as a table marking which methods the
+// concrete type already implements and which are still missing.
+func HoverContent(req *Request) (string, error) {
+	iobj, iface, cobj, T, err := resolveTypes(req)
+	if err != nil {
+		return "", err
+	}
+	qual := types.RelativeTo(cobj.Pkg())
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Methods of `%s` on `%s`:\n\n", iobj.Name(), types.TypeString(T, qual))
+	b.WriteString("| Method | Status |\n| --- | --- |\n")
+	mset := types.NewMethodSet(T)
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		status := "missing"
+		if sel := mset.Lookup(m.Pkg(), m.Name()); sel != nil && types.Identical(sel.Obj().Type(), m.Type()) {
+			status = "implemented"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s |\n", methodSignature(m.Name(), m.Type(), qual), status)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Codec interface {
+	Encode(v int) ([]byte, error)
+	Decode(p []byte) (int, error)
+	Close() error
+}`},
+		"conc": {`package conc
+
+// Good implements Codec fully.
+type Good struct{}
+
+func (g *Good) Encode(v int) ([]byte, error) { return nil, nil }
+func (g *Good) Decode(p []byte) (int, error) { return 0, nil }
+func (g *Good) Close() error                 { return nil }
+
+// Bad is missing Close, has Encode with the wrong signature, and only
+// *Bad has Decode.
+type Bad struct{}
+
+func (b Bad) Encode(v string) ([]byte, error) { return nil, nil }
+func (b *Bad) Decode(p []byte) (int, error)   { return 0, nil }`},
+	})
+
+	explain := func(target string) string {
+		got, err := Explain(&Request{
+			Ctxt:         ctxt,
+			IfacePath:    "iface",
+			IfaceName:    "Codec",
+			ConcretePath: "conc",
+			Target:       target,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got := explain("g *Good"); got != "" {
+		t.Errorf("Explain(*Good) = %q, want empty", got)
+	}
+
+	hover, err := HoverContent(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Codec",
+		ConcretePath: "conc",
+		Target:       "b Bad",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"| Method | Status |",
+		"| `Decode(p []byte) (int, error)` | missing |",
+		"| `Close() error` | missing |",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("HoverContent(Bad) missing %q in:\n%s", want, hover)
+		}
+	}
+	hover, err = HoverContent(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Codec",
+		ConcretePath: "conc",
+		Target:       "g *Good",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(hover, "missing") || strings.Count(hover, "implemented") != 3 {
+		t.Errorf("HoverContent(*Good) should mark all three methods implemented:\n%s", hover)
+	}
+
+	got := explain("b Bad")
+	for _, want := range []string{
+		"Bad does not implement Codec:",
+		"missing method Close() error",
+		"wrong signature for method Encode:",
+		"have Encode(v string) ([]byte, error)",
+		"want Encode(v int) ([]byte, error)",
+		"method Decode has a pointer receiver",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Explain(Bad) missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/loader"
+)
+
+// FuncAdapter generates a function-adapter type for a single-method
+// interface, in the http.HandlerFunc pattern: a func type with the
+// method's signature, plus the method itself forwarding to the
+// function.  The adapter is named after the interface — Runner yields
+// RunnerFunc — and is appended to the request's file, whose complete
+// new contents the response carries.  An interface with more (or
+// fewer) than one method is rejected: the pattern only makes sense
+// when a single function can stand in for the whole interface.  Only
+// the request's locating fields are consulted; Target is not needed,
+// since the adapter is a new type.
+func FuncAdapter(req *Request) (*Response, error) {
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.IfaceTypeArgs) > 0 {
+		if err := instantiateInterface(prog, it, req.ConcretePath, req.IfaceTypeArgs); err != nil {
+			return nil, err
+		}
+	}
+
+	info := prog.Package(req.ConcretePath)
+	if info == nil {
+		return nil, fmt.Errorf("package %q not found", req.ConcretePath)
+	}
+	var file *ast.File
+	for _, p := range append([]*loader.PackageInfo{info}, prog.Created...) {
+		for _, f := range p.Files {
+			if path.Base(prog.Fset.Position(f.Pos()).Filename) == req.File {
+				info, file = p, f
+				break
+			}
+		}
+		if file != nil {
+			break
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("no file named %s in package %q", req.File, req.ConcretePath)
+	}
+
+	ct := &concreteType{
+		prog:         prog,
+		pkg:          info,
+		file:         file,
+		includeAll:   true,
+		addedImports: make(map[string]string),
+		pkgNames:     make(map[string]string),
+		ifaceCache:   make(map[string]*ifaceType),
+	}
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	if len(methods) != 1 {
+		return nil, fmt.Errorf("interface %s has %d methods; a function adapter needs exactly one",
+			req.IfaceName, len(methods))
+	}
+	m := methods[0]
+	nameParams(m.sig)
+
+	name := req.IfaceName + "Func"
+	if ct.nameInUse(name) {
+		return nil, fmt.Errorf("the name %s is already in use in package %q", name, req.ConcretePath)
+	}
+	var sig bytes.Buffer
+	if err := printer.Fprint(&sig, token.NewFileSet(), m.sig); err != nil {
+		return nil, err
+	}
+	sigStr := strings.TrimPrefix(sig.String(), "func")
+
+	var args []string
+	if m.sig.Params != nil {
+		for _, f := range m.sig.Params.List {
+			for _, n := range f.Names {
+				args = append(args, n.Name)
+			}
+		}
+		if n := len(args); n > 0 {
+			last := m.sig.Params.List[len(m.sig.Params.List)-1]
+			if _, variadic := last.Type.(*ast.Ellipsis); variadic {
+				args[n-1] += "..."
+			}
+		}
+	}
+	call := fmt.Sprintf("f(%s)", strings.Join(args, ", "))
+	if m.sig.Results != nil && len(m.sig.Results.List) > 0 {
+		call = "return " + call
+	}
+
+	var stubs bytes.Buffer
+	fmt.Fprintf(&stubs, "\n// %s adapts an ordinary function to the %s interface.\ntype %s func%s\n",
+		name, req.IfaceName, name, sigStr)
+	fmt.Fprintf(&stubs, "\nfunc (f %s) %s%s {\n\t%s\n}\n", name, m.name, sigStr, call)
+	methodsOut, err := format.Source(bytes.TrimSpace(stubs.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, prog.Fset, file); err != nil {
+		return nil, err
+	}
+	buf.Write(stubs.Bytes())
+	fset := token.NewFileSet()
+	out, err := parser.ParseFile(fset, "", buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for ipath := range ct.addedImports {
+		paths = append(paths, ipath)
+	}
+	sort.Strings(paths)
+	for _, ipath := range paths {
+		if iname := ct.addedImports[ipath]; iname != ct.pkgNames[ipath] {
+			astutil.AddNamedImport(fset, out, iname, ipath)
+		} else {
+			astutil.AddImport(fset, out, ipath)
+		}
+	}
+	var rendered bytes.Buffer
+	if err := format.Node(&rendered, fset, out); err != nil {
+		return nil, err
+	}
+	return &Response{File: rendered.Bytes(), Methods: methodsOut, Imports: ct.addedImportsList()}, nil
+}
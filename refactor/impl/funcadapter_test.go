@@ -0,0 +1,74 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuncAdapter(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Handler interface {
+	Handle(e events.Event, opts ...string) error
+}`},
+		"conc": {`package conc
+
+type placeholder struct{}`},
+	})
+
+	resp, err := FuncAdapter(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Handler",
+		ConcretePath: "conc",
+		File:         "0.go",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "type HandlerFunc func(e events.Event, opts ...string) error") {
+		t.Errorf("adapter type not generated:\n%s", src)
+	}
+	if !strings.Contains(src, "func (f HandlerFunc) Handle(e events.Event, opts ...string) error {\n\treturn f(e, opts...)\n}") {
+		t.Errorf("forwarding method not generated:\n%s", src)
+	}
+	if !strings.Contains(src, `"events"`) {
+		t.Errorf("events not imported:\n%s", src)
+	}
+}
+
+func TestFuncAdapterMultiMethod(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Conn interface {
+	Open() error
+	Close() error
+}`},
+		"conc": {`package conc
+
+type placeholder struct{}`},
+	})
+
+	_, err := FuncAdapter(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Conn",
+		ConcretePath: "conc",
+		File:         "0.go",
+	})
+	if err == nil || !strings.Contains(err.Error(), "needs exactly one") {
+		t.Fatalf("err = %v, want single-method rejection", err)
+	}
+}
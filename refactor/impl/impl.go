@@ -0,0 +1,2275 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package impl generates method stubs that make a concrete type
+// implement an interface.
+//
+// Given an interface, a concrete type, and a file belonging to the
+// concrete type's package, Implement computes the methods missing from
+// the concrete type's method set and returns the file's contents with
+// stub declarations for those methods appended.  The stubs qualify
+// types from other packages using the names by which those packages are
+// imported in the concrete file, adding imports as needed.
+package impl // import "golang.org/x/tools/refactor/impl"
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/imports"
+)
+
+// A Request describes a single stub-generation operation.
+type Request struct {
+	// Ctxt is the build context used to locate and load packages.
+	// If nil, build.Default is used.
+	Ctxt *build.Context
+
+	// IfacePath is the import path of the package that declares the
+	// interface, and IfaceName the interface's name within it.
+	IfacePath string
+	IfaceName string
+
+	// IfaceTypeArgs instantiates a generic interface with the given
+	// type arguments before the missing methods are computed, so the
+	// generated signatures are concrete.  The arguments are written as
+	// the concrete package would spell them — typically copied from the
+	// assertion that instantiated the interface, var _ I[Arg] = ... —
+	// and resolved in that package's scope.
+	IfaceTypeArgs []string
+
+	// ConcretePath is the import path of the package that declares the
+	// concrete type, and File the base name of the file within that
+	// package to which the generated methods are appended.
+	ConcretePath string
+	File         string
+
+	// TargetFile, if non-empty, is the base name of a new file to
+	// generate the stubs into instead of appending them to File; the
+	// response's File then holds the complete contents of that new
+	// file.  File is still consulted to locate the concrete type, but
+	// its imports do not carry over: the new file imports everything
+	// the stubs need itself.  It cannot be combined with Granular or
+	// EmbedInterface, which edit the original file.
+	TargetFile string
+
+	// TargetPackage sets the package clause of the file generated for
+	// TargetFile.  It must be the concrete type's package name, the
+	// default, or that name with a _test suffix; in the latter case
+	// the generated file imports the package under test.
+	TargetPackage string
+
+	// Target is the receiver of the generated methods, in the form
+	// "f *File".  The receiver name may be omitted, in which case one
+	// is derived from the type name.
+	Target string
+
+	// OrderBySource causes the generated methods to appear in the
+	// order in which they are declared in the interface's source,
+	// rather than in embedding-traversal order.
+	OrderBySource bool
+
+	// OrderAlphabetically causes the generated methods to be sorted by
+	// name.  It is mutually exclusive with OrderBySource.
+	OrderAlphabetically bool
+
+	// Template, if non-empty, is a text/template used to render each
+	// method in place of the built-in one.  It is executed with a
+	// methodData value; see that type for the available fields.
+	Template string
+
+	// ZeroBody causes each generated method body to return the zero
+	// value of each result, in declaration order, instead of
+	// panicking; methods whose results are all named use a bare
+	// return.  It is ignored when Template is set.
+	ZeroBody bool
+
+	// ErrorPackage, if non-empty, is the import path of a package
+	// providing a NotImplemented(method string) error constructor.
+	// Methods whose last result is an error then return
+	// errpkg.NotImplemented("Method") in that position and the zero
+	// value of every other result; methods without a trailing error
+	// get plain zero-value bodies.  The package is imported if the
+	// file does not already import it.  It is ignored when Template is
+	// set and is mutually exclusive with MarkTODO.
+	ErrorPackage string
+
+	// SentinelError, if non-empty, names a package-level error variable
+	// in the concrete package, such as errNotImplemented; methods whose
+	// last result is an error then return it in that position and the
+	// zero value of every other result.  If the variable does not exist
+	// it is declared after the generated methods, initialized with
+	// errors.New, and a note records the addition.  It is ignored when
+	// Template is set and is mutually exclusive with ErrorPackage and
+	// MarkTODO.
+	SentinelError string
+
+	// Registration, if non-empty, is a text/template rendered once
+	// and appended after the generated methods — typically an init
+	// function registering the type with a framework.  It is executed
+	// with a registrationData value carrying the concrete type's name
+	// and target.  Any imports the snippet needs must already be
+	// present in the file; the snippet itself is framework-specific
+	// free text.
+	Registration string
+
+	// MarkTODO renders each body as panic("TODO: implement Method")
+	// under a // TODO(impl): comment, both naming the method, so
+	// unfinished stubs are easy to grep for and fail loudly until
+	// filled in.  It is ignored when Template is set and is mutually
+	// exclusive with ZeroBody.
+	MarkTODO bool
+
+	// MatchDocStyle gives each generated stub a minimal doc comment,
+	// // Method ..., when every existing method of the concrete type in
+	// the file is documented, so the stubs do not break the file's
+	// prevailing style.  A type with no methods in the file yet has no
+	// prevailing style, and the stubs are left undocumented as usual.
+	MatchDocStyle bool
+
+	// SuggestConversions scans the concrete package's top-level
+	// functions for ones whose signature matches a missing method with
+	// the receiver as an extra first parameter, such as
+	// func doThing(f *File, ...).  Matches are reported on the
+	// response as conversion candidates; a client can offer "convert
+	// the function to the method" alongside the generated stub, but
+	// the stubs themselves are unaffected.
+	SuggestConversions bool
+
+	// EmbedInterface satisfies the interface by embedding it in the
+	// concrete struct instead of stubbing every method: an anonymous
+	// field of the interface type is added to the struct, and only
+	// the methods named in Overrides are generated.  Calls to the
+	// rest reach the embedded interface, so adding methods to the
+	// interface later does not break the build.  It cannot be
+	// combined with Granular, since editing the struct declaration
+	// rewrites an existing region.
+	EmbedInterface bool
+
+	// Overrides names the methods to generate anyway when
+	// EmbedInterface is set.
+	Overrides []string
+
+	// NameContext names a method's first parameter ctx when its type
+	// is context.Context and the interface left it blank, naming the
+	// remaining parameters as a forwarding body would, so generated
+	// bodies can thread the context through by its conventional name.
+	// Parameters the interface already names are left alone.
+	NameContext bool
+
+	// NamedResults names a method's results (result, result2, ..., and
+	// err for a trailing error) when the interface left them all blank,
+	// so the body can be filled in by assigning to them.  Names that
+	// would collide with a parameter get a numeric suffix instead.
+	// Methods whose results the interface already names are left alone.
+	NamedResults bool
+
+	// ExplicitOverEmbedded treats methods reaching the concrete type
+	// only by promotion through an embedded field of interface type as
+	// missing, so explicit stubs are generated for them.  Promotion
+	// through such a field panics at run time when the field is nil;
+	// explicit methods make the delegation visible and safe to audit.
+	// Methods promoted from embedded concrete types are still skipped.
+	ExplicitOverEmbedded bool
+
+	// OrganizeImports runs the resulting file through goimports-style
+	// import organization, so applying the change and then organizing
+	// imports (as editors do on save) yields no further edits.  It is
+	// ignored when Granular is set, since reorganizing the import
+	// block would rewrite regions the granular path promises to leave
+	// untouched.
+	OrganizeImports bool
+
+	// AllowErrors tolerates type errors in the loaded packages.  A
+	// package with a not-yet-satisfied interface assertion does not
+	// type-check, so such errors are always tolerated: when a strict
+	// load fails, the packages are reloaded permissively and the
+	// operation proceeds if the interface and the concrete type still
+	// resolve.  Setting AllowErrors skips the strict attempt entirely,
+	// which avoids type-checking the packages twice when the caller
+	// already knows they are mid-edit.
+	AllowErrors bool
+
+	// Granular computes File by splicing the formatted methods block
+	// and any new import statement into the original contents as
+	// discrete edits, instead of reprinting and reformatting the whole
+	// file.  Untouched regions are preserved byte for byte, which
+	// keeps diffs minimal and avoids O(file) reformatting cost.
+	Granular bool
+
+	// InsertLine, if positive, is the 1-based line at whose start the
+	// methods block should be inserted, for clients that know where the
+	// user wants it — typically the cursor line.  The line must fall on
+	// a boundary between top-level declarations; otherwise, or when
+	// unset, the methods go at the end of the file as usual.  It is
+	// honored by the Granular path, which splices the original bytes.
+	InsertLine int
+
+	// RegionMarkers wraps the generated methods in sentinel comments,
+	// "// impl:begin Iface" and "// impl:end Iface", so regeneration
+	// pipelines can locate the generated region without parsing method
+	// bodies.  A later run for the same interface replaces the marked
+	// region, methods and all, instead of appending a second copy.  It
+	// is ignored when Granular is set, which promises to splice only
+	// into untouched originals.
+	RegionMarkers bool
+
+	// CanonicalPaths maps a loaded package path to the import path the
+	// generated code should use for it.  When a module is developed
+	// against a local copy of a dependency via a replace directive, the
+	// dependency's packages load under the on-disk path; without the
+	// mapping the generated imports would name a path that only resolves
+	// on this machine.
+	CanonicalPaths map[string]string
+
+	// Version is an opaque document version supplied by the caller,
+	// echoed back on the response.  Editors use it to reject the edits
+	// if the document changed between request and application.
+	Version int64
+}
+
+// A Response carries the results of a stub-generation operation.
+type Response struct {
+	// File is the new contents of the target file with the stubs, and
+	// any imports they require, appended.
+	File []byte
+
+	// Methods holds just the generated method declarations, formatted
+	// on their own.  Clients that cannot or do not want to write the
+	// file (say, to copy the stubs somewhere else) can use this
+	// instead of File.
+	Methods []byte
+
+	// Notes lists informational messages about how the stubs were
+	// generated, such as when the interface's source was unavailable
+	// and type information was used instead.
+	Notes []string
+
+	// Imports lists the imports the generated methods require, with
+	// the local names under which they were added to the file.
+	Imports []*AddedImport
+
+	// Conversions lists existing top-level functions that could become
+	// one of the missing methods instead of a stub.  It is populated
+	// only when the request set SuggestConversions.
+	Conversions []*Conversion
+
+	// Offset is the byte offset in the original file at which Methods
+	// can be inserted.  It is set only on the granular path, which is
+	// the only one that works in terms of the original contents.
+	Offset int
+
+	// Edits transforms the file's original contents into File with
+	// position-anchored replacements, so a client can apply the change
+	// without clobbering regions it knows were untouched.  It is empty
+	// when the original contents could not be read.
+	Edits []Edit
+
+	// Version echoes the request's document version.
+	Version int64
+}
+
+// ActionTitle returns a label for the quick fix that applies the
+// response, such as "Implement io.Writer (adds import "bytes")".  The
+// change rewrites the whole file, so on a large one an added import is
+// easy to overlook; naming the imports in the title lets the user see
+// the full effect before accepting.  iface is the interface as the
+// user would write it, e.g. "io.Writer".
+func (r *Response) ActionTitle(iface string) string {
+	title := "Implement " + iface
+	if len(r.Imports) == 0 {
+		return title
+	}
+	var specs []string
+	for _, imp := range r.Imports {
+		spec := fmt.Sprintf("%q", imp.Path)
+		if imp.Name != "" && imp.Name != path.Base(imp.Path) {
+			spec = imp.Name + " " + spec
+		}
+		specs = append(specs, spec)
+	}
+	noun := "import"
+	if len(specs) > 1 {
+		noun = "imports"
+	}
+	return fmt.Sprintf("%s (adds %s %s)", title, noun, strings.Join(specs, ", "))
+}
+
+// An Edit is a single contiguous replacement of the byte range
+// [Start, End) of the original file contents with Text.
+type Edit struct {
+	Start, End int
+	Text       string
+}
+
+// A Conversion describes a top-level function in the concrete package
+// whose signature matches a missing method, with the receiver as the
+// function's first parameter.
+type Conversion struct {
+	Func   string // the function's name
+	Method string // the missing method it could become
+	Title  string // a human-readable label, e.g. "Convert doThing to method Thing"
+}
+
+// Implement computes the method stubs needed for the request's concrete
+// type to implement its interface.
+func Implement(req *Request) (*Response, error) {
+	if req.OrderBySource && req.OrderAlphabetically {
+		return nil, fmt.Errorf("OrderBySource and OrderAlphabetically are mutually exclusive")
+	}
+	if req.EmbedInterface && req.Granular {
+		return nil, fmt.Errorf("EmbedInterface and Granular are mutually exclusive")
+	}
+	if req.MarkTODO && req.ZeroBody {
+		return nil, fmt.Errorf("MarkTODO and ZeroBody are mutually exclusive")
+	}
+	if req.MarkTODO && req.ErrorPackage != "" {
+		return nil, fmt.Errorf("MarkTODO and ErrorPackage are mutually exclusive")
+	}
+	if req.SentinelError != "" && req.ErrorPackage != "" {
+		return nil, fmt.Errorf("SentinelError and ErrorPackage are mutually exclusive")
+	}
+	if req.SentinelError != "" && req.MarkTODO {
+		return nil, fmt.Errorf("SentinelError and MarkTODO are mutually exclusive")
+	}
+	if req.TargetFile != "" && (req.Granular || req.EmbedInterface) {
+		return nil, fmt.Errorf("TargetFile cannot be combined with Granular or EmbedInterface")
+	}
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	// A pure constraint (type terms, no methods) cannot be implemented
+	// by declaring methods; say so instead of generating nothing.
+	if obj, ok := it.pkg.Pkg.Scope().Lookup(req.IfaceName).(*types.TypeName); ok {
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok &&
+			iface.NumMethods() == 0 && !iface.Empty() {
+			return nil, fmt.Errorf("%s is a type constraint, not a method-set interface", req.IfaceName)
+		}
+	}
+	// A generic interface cannot be implemented as-is: its methods
+	// mention type parameters that have no meaning in the concrete
+	// package.  Asking for the missing arguments beats failing on the
+	// first unresolvable signature.
+	if obj, ok := it.pkg.Pkg.Scope().Lookup(req.IfaceName).(*types.TypeName); ok && len(req.IfaceTypeArgs) == 0 {
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			return nil, fmt.Errorf("interface %s requires type arguments; e.g. %s[SomeType]",
+				req.IfaceName, req.IfaceName)
+		}
+	}
+	if len(req.IfaceTypeArgs) > 0 {
+		if err := instantiateInterface(prog, it, req.ConcretePath, req.IfaceTypeArgs); err != nil {
+			return nil, err
+		}
+	}
+
+	ct, err := newConcreteType(prog, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.TargetFile != "" {
+		// Qualification decisions must not assume the original file's
+		// imports, so the rest of the generation works against an
+		// empty file with the requested package clause.
+		if err := ct.retargetNewFile(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if req.RegionMarkers && !req.Granular {
+		ct.region = req.IfaceName
+		ct.findRegion()
+	}
+
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	if req.EmbedInterface {
+		override := make(map[string]bool, len(req.Overrides))
+		for _, name := range req.Overrides {
+			override[name] = true
+		}
+		kept := methods[:0]
+		for _, m := range methods {
+			if override[m.name] {
+				kept = append(kept, m)
+			}
+		}
+		methods = kept
+		if err := ct.embedInterface(it); err != nil {
+			return nil, err
+		}
+	}
+	if err := ct.checkFieldCollisions(methods); err != nil {
+		return nil, err
+	}
+	// The ordering options apply to the deduplicated list, so the
+	// output is stable no matter how often a method is re-declared by
+	// embedded interfaces.
+	switch {
+	case req.OrderBySource:
+		sort.SliceStable(methods, func(i, j int) bool {
+			return methods[i].pos < methods[j].pos
+		})
+	case req.OrderAlphabetically:
+		sort.SliceStable(methods, func(i, j int) bool {
+			return methods[i].name < methods[j].name
+		})
+	}
+
+	if req.NameContext {
+		for _, m := range methods {
+			nameContextParam(m)
+		}
+	}
+	if req.NamedResults {
+		for _, m := range methods {
+			nameResults(m)
+		}
+	}
+
+	var conversions []*Conversion
+	if req.SuggestConversions {
+		conversions = ct.conversionCandidates(methods)
+	}
+
+	var sentinelNote string
+	tmpl := defaultTemplate
+	if req.Template != "" {
+		tmpl, err = template.New("method").Parse(req.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid method template: %v", err)
+		}
+	} else if req.ZeroBody || req.ErrorPackage != "" || req.SentinelError != "" {
+		tmpl = zeroTemplate
+		ct.zeroBody = true
+		ct.errPkg = req.ErrorPackage
+		if req.SentinelError != "" {
+			created, err := ct.useSentinel(req.SentinelError)
+			if err != nil {
+				return nil, err
+			}
+			if created {
+				sentinelNote = fmt.Sprintf("error variable %s was not found and has been declared", req.SentinelError)
+			}
+		}
+	} else if req.MarkTODO {
+		tmpl = markTODOTemplate
+	}
+	if req.MatchDocStyle {
+		ct.docStubs = ct.prevailingDocStyle()
+	}
+	if req.Registration != "" {
+		rt, err := template.New("registration").Parse(req.Registration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registration template: %v", err)
+		}
+		tname := ""
+		if named, ok := ct.typ.(*types.Named); ok {
+			tname = named.Obj().Name()
+		}
+		var b bytes.Buffer
+		if err := rt.Execute(&b, registrationData{
+			Type:   tname,
+			Target: strings.TrimSpace(strings.TrimPrefix(ct.recv, strings.Fields(ct.recv)[0])),
+		}); err != nil {
+			return nil, fmt.Errorf("executing registration template: %v", err)
+		}
+		ct.registration = "\n" + strings.TrimSpace(b.String()) + "\n"
+	}
+
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	filename := prog.Fset.Position(ct.file.Pos()).Filename
+
+	var resp *Response
+	if req.TargetFile != "" {
+		if resp, err = ct.newFileStubs(methods, tmpl); err != nil {
+			return nil, err
+		}
+	} else if req.Granular {
+		orig, err := readFile(ctxt, filename)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %v", filename, err)
+		}
+		ct.insertLine = req.InsertLine
+		if resp, err = ct.granularStubs(orig, methods, tmpl); err != nil {
+			return nil, err
+		}
+	} else {
+		if resp, err = ct.sourceWithStubs(methods, tmpl); err != nil {
+			return nil, err
+		}
+		if req.OrganizeImports {
+			organized, err := imports.Process(filename, resp.File, nil)
+			if err != nil {
+				return nil, fmt.Errorf("organizing imports: %v", err)
+			}
+			resp.File = organized
+		}
+		if orig, err := readFile(ctxt, filename); err == nil {
+			resp.Edits = computeEdits(orig, resp.File)
+		}
+	}
+	if it.decl == nil && !it.instantiated {
+		resp.Notes = append(resp.Notes,
+			fmt.Sprintf("source for interface %s is unavailable; stubs were generated from type information", req.IfaceName))
+	}
+	if sentinelNote != "" {
+		resp.Notes = append(resp.Notes, sentinelNote)
+	}
+	for _, m := range methods {
+		if m.todo != "" {
+			resp.Notes = append(resp.Notes, fmt.Sprintf("method %s: %s", m.name, m.todo))
+		}
+	}
+	resp.Conversions = conversions
+	resp.Version = req.Version
+	return resp, nil
+}
+
+// readFile reads a file through the build context's file access
+// methods, so fake and overlaid file systems are honored.
+func readFile(ctxt *build.Context, filename string) ([]byte, error) {
+	rc, err := buildutil.OpenFile(ctxt, filename)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// computeEdits returns the minimal single replacement that transforms
+// orig into updated: the differing middle region between the longest
+// common prefix and suffix.  It returns nil if the contents are equal.
+func computeEdits(orig, updated []byte) []Edit {
+	if bytes.Equal(orig, updated) {
+		return nil
+	}
+	p := 0
+	for p < len(orig) && p < len(updated) && orig[p] == updated[p] {
+		p++
+	}
+	s := 0
+	for s < len(orig)-p && s < len(updated)-p && orig[len(orig)-1-s] == updated[len(updated)-1-s] {
+		s++
+	}
+	return []Edit{{Start: p, End: len(orig) - s, Text: string(updated[p : len(updated)-s])}}
+}
+
+// Flatten generates explicit forwarding methods on a struct type that
+// embeds the request's interface as a field, delegating every method
+// of the interface to that field.  This makes the delegation visible
+// (and overridable method by method) instead of relying on promotion,
+// which panics at run time if the field was never set.
+func Flatten(req *Request) (*Response, error) {
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := newConcreteType(prog, req)
+	if err != nil {
+		return nil, err
+	}
+
+	st, ok := ct.typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct", types.TypeString(ct.typ, nil))
+	}
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if !f.Anonymous() {
+			continue
+		}
+		if named, ok := f.Type().(*types.Named); ok &&
+			named.Obj().Pkg() != nil &&
+			named.Obj().Pkg().Path() == req.IfacePath &&
+			named.Obj().Name() == req.IfaceName {
+			ct.forwardField = f.Name()
+			break
+		}
+	}
+	if ct.forwardField == "" {
+		return nil, fmt.Errorf("%s does not embed %s", types.TypeString(ct.typ, nil), req.IfaceName)
+	}
+
+	ct.includeAll = true
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range methods {
+		nameParams(m.sig)
+	}
+	return ct.sourceWithStubs(methods, forwardTemplate)
+}
+
+// nameParams gives every unnamed parameter a synthesized name so a
+// forwarding body can reference it.
+func nameParams(ft *ast.FuncType) {
+	if ft.Params == nil {
+		return
+	}
+	i := 0
+	for _, f := range ft.Params.List {
+		if len(f.Names) == 0 {
+			f.Names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("a%d", i))}
+		}
+		i += len(f.Names)
+	}
+}
+
+// nameContextParam names the method's first parameter ctx if it is an
+// unnamed context.Context.  Go forbids mixing named and unnamed
+// parameters, so the remaining parameters are then named as a
+// forwarding body would name them.
+func nameContextParam(m *method) {
+	if m.typ == nil || m.typ.Params().Len() == 0 ||
+		m.sig.Params == nil || len(m.sig.Params.List) == 0 {
+		return
+	}
+	named, ok := m.typ.Params().At(0).Type().(*types.Named)
+	if !ok {
+		return
+	}
+	obj := named.Obj()
+	if obj.Name() != "Context" || obj.Pkg() == nil || obj.Pkg().Path() != "context" {
+		return
+	}
+	// An unnamed parameter is a Field of its own, so naming the first
+	// field cannot rename a neighbor.
+	if first := m.sig.Params.List[0]; len(first.Names) == 0 {
+		first.Names = []*ast.Ident{ast.NewIdent("ctx")}
+		nameParams(m.sig)
+	}
+}
+
+// nameResults names the method's results so a body can assign to them:
+// result, result2, ..., with a trailing error named err.  Results the
+// interface named itself are respected, and a name already taken by a
+// parameter gets a numeric suffix.
+func nameResults(m *method) {
+	res := m.sig.Results
+	if res == nil || len(res.List) == 0 {
+		return
+	}
+	for _, f := range res.List {
+		if len(f.Names) > 0 {
+			return
+		}
+	}
+	used := make(map[string]bool)
+	if m.sig.Params != nil {
+		for _, f := range m.sig.Params.List {
+			for _, n := range f.Names {
+				used[n.Name] = true
+			}
+		}
+	}
+	pick := func(base string) string {
+		name := base
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s%d", base, i)
+		}
+		used[name] = true
+		return name
+	}
+	for i, f := range res.List {
+		base := "result"
+		if i == len(res.List)-1 {
+			if id, ok := f.Type.(*ast.Ident); ok && id.Name == "error" {
+				base = "err"
+			}
+		}
+		f.Names = []*ast.Ident{ast.NewIdent(pick(base))}
+	}
+}
+
+// loadRequest loads the request's interface and concrete packages.
+func loadRequest(req *Request) (*loader.Program, error) {
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	load := func(allowErrors bool) (*loader.Program, error) {
+		conf := loader.Config{Build: ctxt, ParserMode: parser.ParseComments}
+		if allowErrors {
+			conf.AllowErrors = true
+			conf.TypeChecker.Error = func(error) {}
+		}
+		conf.Import(req.IfacePath)
+		if strings.HasSuffix(req.File, "_test.go") {
+			// The concrete type may live in the package's test variant,
+			// including the external (foo_test) package.
+			conf.ImportWithTests(req.ConcretePath)
+		} else {
+			conf.Import(req.ConcretePath)
+		}
+		return conf.Load()
+	}
+	prog, err := load(req.AllowErrors)
+	if err != nil && !req.AllowErrors {
+		// A package that is mid-edit often has a type error in a file
+		// unrelated to the request.  Retry permissively: the lookups
+		// that follow still fail with a precise message if the interface
+		// or the concrete type themselves do not resolve.
+		if prog, retryErr := load(true); retryErr == nil {
+			return prog, nil
+		}
+		// The strict load's error is the informative one.
+		return nil, err
+	}
+	return prog, err
+}
+
+// An ifaceType is an interface found in a loaded package.  decl is nil
+// when the interface's source is unavailable (for example when its
+// declaring file is a cgo-generated artifact); typ is then used to
+// render signatures from type information alone.  instantiated
+// distinguishes the deliberate types-only form produced by
+// instantiating a generic interface, which deserves no warning.
+type ifaceType struct {
+	name         string
+	pkg          *loader.PackageInfo
+	decl         *ast.InterfaceType
+	typ          *types.Interface
+	instantiated bool
+}
+
+// findInterface locates the declaration of the named interface in the
+// package with the given import path.  Cgo-generated files are skipped;
+// if no source declaration can be found but the package's scope has the
+// interface, a types-only ifaceType is returned.
+func findInterface(prog *loader.Program, ipath, name string) (*ifaceType, error) {
+	info := prog.Package(ipath)
+	if info == nil {
+		return nil, fmt.Errorf("package %q not found", ipath)
+	}
+	for _, f := range info.Files {
+		if isCgoArtifact(path.Base(prog.Fset.Position(f.Pos()).Filename)) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				idecl, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return nil, fmt.Errorf("%s is not an interface", name)
+				}
+				return &ifaceType{name: name, pkg: info, decl: idecl}, nil
+			}
+		}
+	}
+	// The interface may be declared locally, inside a function body;
+	// its method set can still be implemented by a package-level type.
+	for _, f := range info.Files {
+		if isCgoArtifact(path.Base(prog.Fset.Position(f.Pos()).Filename)) {
+			continue
+		}
+		var found *ast.InterfaceType
+		ast.Inspect(f, func(n ast.Node) bool {
+			if found != nil {
+				return false
+			}
+			if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == name {
+				if idecl, ok := ts.Type.(*ast.InterfaceType); ok {
+					found = idecl
+				}
+			}
+			return true
+		})
+		if found != nil {
+			return &ifaceType{name: name, pkg: info, decl: found}, nil
+		}
+	}
+	// No source declaration; fall back to type information.
+	if obj, ok := info.Pkg.Scope().Lookup(name).(*types.TypeName); ok {
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			return &ifaceType{name: name, pkg: info, typ: iface}, nil
+		}
+		return nil, fmt.Errorf("%s is not an interface", name)
+	}
+	return nil, fmt.Errorf("no interface named %s in package %q", name, ipath)
+}
+
+// instantiateInterface replaces a generic ifaceType's method source
+// with the instance the type arguments name, substituting the type
+// parameters away.  The source declaration still spells the parameters,
+// so the ifaceType is demoted to its types-only form, where the
+// substituted signatures are available.  The arguments are resolved in
+// the scope of the package with the given import path, where the
+// assertion that supplied them was written.
+func instantiateInterface(prog *loader.Program, it *ifaceType, argPkg string, args []string) error {
+	obj, ok := it.pkg.Pkg.Scope().Lookup(it.name).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("no type named %s in package %q", it.name, it.pkg.Pkg.Path())
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() == 0 {
+		return fmt.Errorf("%s is not a generic interface", it.name)
+	}
+	if got, want := len(args), named.TypeParams().Len(); got != want {
+		return fmt.Errorf("%s has %d type parameters, got %d type arguments", it.name, want, got)
+	}
+	scope := prog.Package(argPkg)
+	if scope == nil {
+		return fmt.Errorf("package %q not found", argPkg)
+	}
+	// A qualified argument such as events.User resolves through a file
+	// scope, where the import's name is bound, so each file's scope is
+	// tried after the bare package scope.
+	positions := []token.Pos{token.NoPos}
+	for _, f := range scope.Files {
+		positions = append(positions, f.Name.End())
+	}
+	var targs []types.Type
+	for _, arg := range args {
+		var typ types.Type
+		var lastErr error
+		for _, pos := range positions {
+			tv, err := types.Eval(prog.Fset, scope.Pkg, pos, arg)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if !tv.IsType() {
+				lastErr = fmt.Errorf("%s is not a type", arg)
+				continue
+			}
+			typ = tv.Type
+			break
+		}
+		if typ == nil {
+			return fmt.Errorf("cannot resolve type argument %q: %v", arg, lastErr)
+		}
+		targs = append(targs, typ)
+	}
+	inst, err := types.Instantiate(nil, named, targs, true)
+	if err != nil {
+		return fmt.Errorf("cannot instantiate %s: %v", it.name, err)
+	}
+	iface, ok := inst.Underlying().(*types.Interface)
+	if !ok {
+		return fmt.Errorf("%s is not an interface", it.name)
+	}
+	it.decl = nil
+	it.typ = iface
+	it.instantiated = true
+	return nil
+}
+
+// A concreteType holds the state for generating stubs into a single
+// file of the concrete type's package.
+type concreteType struct {
+	prog *loader.Program
+	pkg  *loader.PackageInfo
+	file *ast.File
+	typ  types.Type // the concrete named type
+	recv string     // receiver, e.g. "f *File"
+
+	// addedImports records the imports added during this generation
+	// run, keyed by import path; the value is the local name by which
+	// the package will be known in the file.
+	addedImports map[string]string
+
+	// pkgNames records the declared package name for each path in
+	// addedImports, so the import spec can omit a redundant name.
+	pkgNames map[string]string
+
+	// includeAll collects every interface method rather than only the
+	// missing ones; Flatten needs this since an embedded interface
+	// already promotes them all.
+	includeAll bool
+
+	// forwardField, if non-empty, names the struct field that the
+	// generated methods delegate to.
+	forwardField string
+
+	// zeroBody causes renderStubs to compute methodData.Return, the
+	// zero-value return statement for each method.
+	zeroBody bool
+
+	// registration is the rendered registration snippet to append
+	// after the generated methods, or "".
+	registration string
+
+	// errPkg is the import path of the NotImplemented error helper
+	// package, or "" when plain zero values are returned.
+	errPkg string
+
+	// docStubs gives each generated stub a minimal doc comment, to
+	// match a file whose existing methods are all documented.
+	docStubs bool
+
+	// insertLine is the requested methods-block insertion line for the
+	// granular path, or 0 for the default end-of-file placement.
+	insertLine int
+
+	// sentinel names the package-level error variable that zero-value
+	// bodies return in a trailing error position, or "".  sentinelDecl
+	// is the variable's declaration, rendered after the methods when
+	// the package does not have it yet.
+	sentinel     string
+	sentinelDecl string
+
+	// targetPkg is the package clause of the new file being generated
+	// for Request.TargetFile, or "".
+	targetPkg string
+
+	// explicitOverEmbedded makes hasMethod treat methods promoted
+	// through interface-typed embedded fields as missing.
+	explicitOverEmbedded bool
+
+	// ifaceCache memoizes resolveEmbedded results within one
+	// invocation, keyed by the interface's package path and name.
+	ifaceCache map[string]*ifaceType
+
+	// canonicalPaths maps loaded package paths to the import paths the
+	// generated code should use, per Request.CanonicalPaths.
+	canonicalPaths map[string]string
+
+	// strategies maps method names to the per-method rendering strategy
+	// chosen through ImplementPerMethod; methods not in the map render
+	// through the run's default template.
+	strategies map[string]string
+
+	// region is the label of the sentinel comments wrapping the
+	// generated methods, per Request.RegionMarkers, or "".  regionStart
+	// and regionEnd bound a previous run's marked region in the file;
+	// methods declared inside it are regenerated rather than treated as
+	// already implemented.
+	region                 string
+	regionStart, regionEnd token.Pos
+
+	// inaccessible accumulates qualified names of unexported types
+	// from other packages that rewriteExpr had to reference anyway;
+	// missingMethods drains it after each signature to flag the
+	// offending method.
+	inaccessible []string
+}
+
+func newConcreteType(prog *loader.Program, req *Request) (*concreteType, error) {
+	info := prog.Package(req.ConcretePath)
+	if info == nil {
+		return nil, fmt.Errorf("package %q not found", req.ConcretePath)
+	}
+
+	// The file may belong to the package itself or, for _test.go
+	// files, to the external test package created alongside it; the
+	// concrete type must be looked up in whichever variant declares
+	// the file.
+	var file *ast.File
+	for _, p := range append([]*loader.PackageInfo{info}, prog.Created...) {
+		for _, f := range p.Files {
+			if path.Base(prog.Fset.Position(f.Pos()).Filename) == req.File {
+				info, file = p, f
+				break
+			}
+		}
+		if file != nil {
+			break
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("no file named %s in package %q", req.File, req.ConcretePath)
+	}
+
+	recvName, recvType, err := parseTarget(req.Target)
+	if err != nil {
+		return nil, err
+	}
+	tname := strings.TrimPrefix(recvType, "*")
+	// A target written with instantiation arguments, e.g. "*T[int]",
+	// names the base type; methods are declared on that.
+	if i := strings.Index(tname, "["); i >= 0 {
+		tname = tname[:i]
+	}
+	obj, ok := info.Pkg.Scope().Lookup(tname).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no type named %s in package %q", tname, req.ConcretePath)
+	}
+	// Methods on a generic type repeat its type parameters in the
+	// receiver, by their declared names regardless of what the target
+	// was written with.
+	if named, ok := obj.Type().(*types.Named); ok {
+		if tp := named.TypeParams(); tp.Len() > 0 {
+			params := make([]string, tp.Len())
+			for i := range params {
+				params[i] = tp.At(i).Obj().Name()
+			}
+			recvType = tname + "[" + strings.Join(params, ", ") + "]"
+			if strings.HasPrefix(req.Target, "*") || strings.Contains(req.Target, " *") {
+				recvType = "*" + recvType
+			}
+		}
+	}
+	if types.IsInterface(obj.Type()) {
+		return nil, fmt.Errorf("%s is an interface, not a concrete type", tname)
+	}
+	// An alias for a type defined elsewhere cannot receive methods
+	// here; they belong in the defining package.
+	if obj.IsAlias() {
+		if named, ok := obj.Type().(*types.Named); ok && named.Obj().Pkg() != nil && named.Obj().Pkg() != info.Pkg {
+			return nil, fmt.Errorf("%s is an alias for %s.%s; methods must be declared in package %q",
+				tname, named.Obj().Pkg().Name(), named.Obj().Name(), named.Obj().Pkg().Path())
+		}
+	}
+	// An alias within the package is transparent — methods declared on
+	// it belong to the aliased type — but when that type is generic the
+	// receiver must be spelled with the real type's name and parameter
+	// list, which the alias's name does not carry.
+	if obj.IsAlias() {
+		if named, ok := obj.Type().(*types.Named); ok && named.TypeParams().Len() > 0 {
+			if named.TypeArgs().Len() > 0 {
+				return nil, fmt.Errorf("cannot define methods on %s: it aliases an instantiated generic type", tname)
+			}
+			star := ""
+			if strings.HasPrefix(recvType, "*") {
+				star = "*"
+			}
+			var params []string
+			for i := 0; i < named.TypeParams().Len(); i++ {
+				params = append(params, named.TypeParams().At(i).Obj().Name())
+			}
+			recvType = star + named.Obj().Name() + "[" + strings.Join(params, ", ") + "]"
+		}
+	}
+	// The spec forbids methods on some receiver base types; catch
+	// them here rather than emitting declarations that cannot
+	// compile.
+	switch obj.Type().Underlying().(type) {
+	case *types.Pointer:
+		return nil, fmt.Errorf("cannot define methods on named pointer type %s", tname)
+	}
+	if _, ok := obj.Type().(*types.Named); !ok {
+		return nil, fmt.Errorf("cannot define methods on %s: not a defined type", tname)
+	}
+
+	return &concreteType{
+		prog:                 prog,
+		pkg:                  info,
+		file:                 file,
+		typ:                  obj.Type(),
+		recv:                 recvName + " " + recvType,
+		addedImports:         make(map[string]string),
+		pkgNames:             make(map[string]string),
+		ifaceCache:           make(map[string]*ifaceType),
+		canonicalPaths:       req.CanonicalPaths,
+		explicitOverEmbedded: req.ExplicitOverEmbedded,
+	}, nil
+}
+
+// parseTarget splits a target of the form "f *File" (or just "*File")
+// into a receiver name and type.
+func parseTarget(target string) (name, typ string, err error) {
+	// Split on the first space only: a generic type such as
+	// "*Cache[K, V]" contains spaces of its own.
+	switch fields := strings.SplitN(strings.TrimSpace(target), " ", 2); len(fields) {
+	case 1:
+		typ = fields[0]
+		r, size := utf8.DecodeRuneInString(strings.TrimPrefix(typ, "*"))
+		if size == 0 {
+			return "", "", fmt.Errorf("invalid target %q", target)
+		}
+		name = string(unicode.ToLower(r))
+	case 2:
+		name, typ = fields[0], strings.TrimSpace(fields[1])
+	default:
+		return "", "", fmt.Errorf("invalid target %q; expected \"f *File\"", target)
+	}
+	return name, typ, nil
+}
+
+// A method is a single stub to be generated.
+type method struct {
+	name string
+	sig  *ast.FuncType    // rewritten to be valid in the concrete file
+	typ  *types.Signature // type of the method; nil if unavailable
+	pos  token.Pos        // position of the declaration in the interface
+	todo string           // problem to surface as a TODO in the body, if any
+}
+
+// missingMethods returns, in embedding-traversal order, the methods of
+// the interface that the concrete type does not already have.  The seen
+// map carries method names across embedded interfaces so that each
+// method is collected at most once.  The visited map records the
+// interfaces already being traversed, keyed by package path and name;
+// a malformed type graph (possible mid-edit) may embed an interface in
+// itself, and the guard turns that into an error instead of unbounded
+// recursion.
+func (ct *concreteType) missingMethods(it *ifaceType, seen, visited map[string]bool) ([]*method, error) {
+	if it.decl == nil {
+		return ct.typesMissingMethods(it, seen)
+	}
+	key := it.pkg.Pkg.Path() + "." + it.name
+	if visited[key] {
+		return nil, fmt.Errorf("interface embedding cycle involving %s", key)
+	}
+	visited[key] = true
+	defer delete(visited, key) // diamonds are fine, only cycles are not
+	var methods []*method
+	for _, field := range it.decl.Methods.List {
+		if len(field.Names) == 0 {
+			// Universe interfaces need no declaration lookup: error
+			// contributes its Error method, and any and comparable
+			// contribute no methods at all.
+			if id, ok := field.Type.(*ast.Ident); ok {
+				if obj, ok := it.pkg.Info.Uses[id].(*types.TypeName); ok && obj.Pkg() == nil {
+					if id.Name == "error" && !seen["Error"] {
+						seen["Error"] = true
+						if ct.includeAll || !ct.hasMethod(it.pkg, "Error") {
+							methods = append(methods, errorMethod(field.Pos()))
+						}
+					}
+					continue
+				}
+			}
+			// A type term (~T or a union) or a plain non-interface
+			// type constrains implementations but contributes no
+			// methods; only interface embeddings are traversed.
+			switch field.Type.(type) {
+			case *ast.UnaryExpr, *ast.BinaryExpr:
+				continue
+			}
+			if t := it.pkg.Info.TypeOf(field.Type); t != nil && !types.IsInterface(t) {
+				// An invalid type (broken code mid-edit) is not a
+				// deliberate type term; let resolveEmbedded and the
+				// cycle guard report it.
+				if b, ok := t.Underlying().(*types.Basic); !ok || b.Kind() != types.Invalid {
+					continue
+				}
+			}
+			// An instantiated generic interface: its source
+			// declaration still has the type parameters, so collect
+			// the methods from type information, where the arguments
+			// have been substituted.
+			if named, ok := it.pkg.Info.TypeOf(field.Type).(*types.Named); ok && named.TypeArgs().Len() > 0 {
+				emPkg := it.pkg
+				if p := ct.prog.Package(named.Obj().Pkg().Path()); p != nil {
+					emPkg = p
+				}
+				emb := &ifaceType{
+					name: named.Obj().Name(),
+					pkg:  emPkg,
+					typ:  named.Underlying().(*types.Interface),
+				}
+				ms, err := ct.typesMissingMethods(emb, seen)
+				if err != nil {
+					return nil, err
+				}
+				methods = append(methods, ms...)
+				continue
+			}
+			// Embedded interface.
+			embedded, err := ct.resolveEmbedded(it.pkg, field.Type)
+			if err != nil {
+				return nil, err
+			}
+			ms, err := ct.missingMethods(embedded, seen, visited)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, ms...)
+			continue
+		}
+		name := field.Names[0].Name
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if !ast.IsExported(name) && it.pkg.Pkg != ct.pkg.Pkg {
+			return nil, fmt.Errorf("interface %s has unexported method %s and cannot be implemented outside package %q",
+				it.name, name, it.pkg.Pkg.Path())
+		}
+		if !ct.includeAll && ct.hasMethod(it.pkg, name) {
+			continue
+		}
+		typ, _ := it.pkg.Info.TypeOf(field.Type).(*types.Signature)
+		sig := ct.rewriteExpr(it.pkg, field.Type).(*ast.FuncType)
+		var todo string
+		if n := len(ct.inaccessible); n > 0 {
+			plural := "type " + ct.inaccessible[0] + " is"
+			if n > 1 {
+				plural = "types " + strings.Join(ct.inaccessible, ", ") + " are"
+			}
+			todo = "unexported " + plural + " not accessible here"
+			ct.inaccessible = nil
+		}
+		methods = append(methods, &method{
+			name: name,
+			sig:  sig,
+			typ:  typ,
+			pos:  field.Pos(),
+			todo: todo,
+		})
+	}
+	return methods, nil
+}
+
+// isCgoArtifact reports whether base names a cgo-generated file, such
+// as _cgo_gotypes.go.  Such files do not correspond to code the user
+// wrote and are not useful for signature rendering.
+func isCgoArtifact(base string) bool {
+	return strings.HasPrefix(base, "_cgo_")
+}
+
+// typesMissingMethods collects the missing methods from type
+// information alone, without consulting the interface's source.  The
+// method set of a *types.Interface already includes embedded methods,
+// so no traversal is needed.
+func (ct *concreteType) typesMissingMethods(it *ifaceType, seen map[string]bool) ([]*method, error) {
+	var methods []*method
+	for i := 0; i < it.typ.NumMethods(); i++ {
+		f := it.typ.Method(i)
+		if seen[f.Name()] {
+			continue
+		}
+		seen[f.Name()] = true
+		if !f.Exported() && f.Pkg() != ct.pkg.Pkg {
+			return nil, fmt.Errorf("interface %s has unexported method %s and cannot be implemented outside package %q",
+				it.name, f.Name(), f.Pkg().Path())
+		}
+		if !ct.includeAll && ct.hasMethod(it.pkg, f.Name()) {
+			continue
+		}
+		sigStr := types.TypeString(f.Type(), func(pkg *types.Package) string {
+			if pkg == ct.pkg.Pkg {
+				return ""
+			}
+			return ct.packageName(pkg)
+		})
+		x, err := parser.ParseExpr(sigStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot render signature of %s: %v", f.Name(), err)
+		}
+		methods = append(methods, &method{
+			name: f.Name(),
+			sig:  x.(*ast.FuncType),
+			typ:  f.Type().(*types.Signature),
+			pos:  f.Pos(),
+		})
+	}
+	return methods, nil
+}
+
+// resolveEmbedded locates the declaration of an interface embedded in
+// another.  Universe types (error, any, comparable) are handled by the
+// caller before this point.
+func (ct *concreteType) resolveEmbedded(from *loader.PackageInfo, x ast.Expr) (*ifaceType, error) {
+	named, ok := from.Info.TypeOf(x).(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("embedded type %s is not a named interface", types.ExprString(x))
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return nil, fmt.Errorf("cannot embed universe type %s", obj.Name())
+	}
+	// Diamond-shaped embeddings resolve the same interface many
+	// times, and each resolution scans its package's files; memoize
+	// within the invocation so repeats are map lookups.
+	key := obj.Pkg().Path() + "." + obj.Name()
+	if it, ok := ct.ifaceCache[key]; ok {
+		return it, nil
+	}
+	it, err := findInterface(ct.prog, obj.Pkg().Path(), obj.Name())
+	if err == nil {
+		ct.ifaceCache[key] = it
+	}
+	return it, err
+}
+
+// errorMethod returns the stub for the Error method of the universe
+// error interface.
+func errorMethod(pos token.Pos) *method {
+	return &method{
+		name: "Error",
+		sig: &ast.FuncType{
+			Params: &ast.FieldList{},
+			Results: &ast.FieldList{
+				List: []*ast.Field{{Type: ast.NewIdent("string")}},
+			},
+		},
+		typ: types.NewSignature(nil, nil,
+			types.NewTuple(types.NewVar(pos, nil, "", types.Typ[types.String])), false),
+		pos: pos,
+	}
+}
+
+// hasMethod reports whether the concrete type already has a method with
+// the given name.  The lookup is made from the interface's package so
+// that unexported methods are treated as the interface sees them.
+func (ct *concreteType) hasMethod(it *loader.PackageInfo, name string) bool {
+	obj, index, _ := types.LookupFieldOrMethod(types.NewPointer(ct.typ), true, it.Pkg, name)
+	f, ok := obj.(*types.Func)
+	if !ok || f == nil {
+		return false
+	}
+	if ct.regionStart.IsValid() && f.Pos() >= ct.regionStart && f.Pos() <= ct.regionEnd {
+		// The method is a previous run's output inside the marked
+		// region, which this run replaces wholesale.
+		return false
+	}
+	if ct.explicitOverEmbedded && len(index) > 1 {
+		// The method is promoted.  If any step of the embedding chain
+		// is a field of interface type, the promotion dereferences
+		// that field at run time; treat the method as missing so an
+		// explicit stub shadows it.
+		t := ct.typ
+		for _, i := range index[:len(index)-1] {
+			if ptr, ok := t.Underlying().(*types.Pointer); ok {
+				t = ptr.Elem()
+			}
+			st, ok := t.Underlying().(*types.Struct)
+			if !ok {
+				break
+			}
+			fld := st.Field(i)
+			if types.IsInterface(fld.Type()) {
+				return false
+			}
+			t = fld.Type()
+		}
+	}
+	return true
+}
+
+// findRegion records the span of a previous run's marked region in the
+// concrete file, from its begin marker to the end of its end marker.
+// An unpaired marker is ignored rather than guessed at.
+func (ct *concreteType) findRegion() {
+	begin := "impl:begin " + ct.region
+	end := "impl:end " + ct.region
+	for _, cg := range ct.file.Comments {
+		for _, c := range cg.List {
+			switch strings.TrimSpace(strings.TrimPrefix(c.Text, "//")) {
+			case begin:
+				if !ct.regionStart.IsValid() {
+					ct.regionStart = c.Pos()
+				}
+			case end:
+				ct.regionEnd = c.End()
+			}
+		}
+	}
+	if ct.regionEnd < ct.regionStart {
+		ct.regionStart, ct.regionEnd = token.NoPos, token.NoPos
+	}
+}
+
+// stripRegion removes a marked region, sentinel lines included, from
+// printed source, so the regenerated region can take its place.
+func stripRegion(src []byte, region string) []byte {
+	i := bytes.Index(src, []byte("// impl:begin "+region))
+	if i < 0 {
+		return src
+	}
+	marker := []byte("// impl:end " + region)
+	j := bytes.Index(src[i:], marker)
+	if j < 0 {
+		return src
+	}
+	j = i + j + len(marker)
+	for j < len(src) && (src[j] == '\n' || src[j] == '\r') {
+		j++
+	}
+	return append(src[:i], src[j:]...)
+}
+
+// rewriteExpr returns a copy of a type expression from the interface's
+// package, rewritten so that it is valid in the concrete file: selector
+// qualifiers are renamed to match the concrete file's imports, and
+// identifiers declared at package level in another package are
+// qualified with that package's name.
+func (ct *concreteType) rewriteExpr(from *loader.PackageInfo, x ast.Expr) ast.Expr {
+	switch x := x.(type) {
+	case nil:
+		return nil
+	case *ast.Ident:
+		obj := from.Info.Uses[x]
+		if obj != nil && obj.Pkg() != nil && obj.Pkg() != ct.pkg.Pkg && obj.Parent() == obj.Pkg().Scope() {
+			// An alias for a universe type, such as "type Err = error",
+			// needs no qualification: the builtin means the same thing
+			// in every package, and error cannot be imported anyway.  A
+			// defined type over error is its own type and is qualified
+			// as usual.
+			if tn, ok := obj.(*types.TypeName); ok && tn.IsAlias() {
+				switch t := tn.Type().(type) {
+				case *types.Basic:
+					return ast.NewIdent(t.Name())
+				case *types.Named:
+					if t.Obj().Pkg() == nil {
+						return ast.NewIdent(t.Obj().Name())
+					}
+				}
+			}
+			name := ct.importName(ct.canonicalPath(imports.VendorlessPath(obj.Pkg().Path())), obj.Pkg().Name())
+			// The qualified reference is kept as a placeholder even
+			// when it will not compile; the method is flagged with a
+			// TODO instead of failing the whole generation.
+			if !ast.IsExported(x.Name) {
+				ct.inaccessible = append(ct.inaccessible, name+"."+x.Name)
+			}
+			return &ast.SelectorExpr{X: ast.NewIdent(name), Sel: ast.NewIdent(x.Name)}
+		}
+		return ast.NewIdent(x.Name)
+	case *ast.SelectorExpr:
+		if id, ok := x.X.(*ast.Ident); ok {
+			if pkgName, ok := from.Info.Uses[id].(*types.PkgName); ok {
+				// A qualifier denoting the concrete package itself is
+				// dropped: inside that package the identifier is bare,
+				// however the interface's file imported it.
+				if pkgName.Imported() == ct.pkg.Pkg {
+					return ast.NewIdent(x.Sel.Name)
+				}
+				name := ct.mightRenameSelector(pkgName)
+				if !ast.IsExported(x.Sel.Name) && pkgName.Imported() != ct.pkg.Pkg {
+					ct.inaccessible = append(ct.inaccessible, name+"."+x.Sel.Name)
+				}
+				return &ast.SelectorExpr{
+					X:   ast.NewIdent(name),
+					Sel: ast.NewIdent(x.Sel.Name),
+				}
+			}
+		}
+		return &ast.SelectorExpr{X: ct.rewriteExpr(from, x.X), Sel: ast.NewIdent(x.Sel.Name)}
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: ct.rewriteExpr(from, x.X)}
+	case *ast.IndexExpr:
+		// A generic instantiation such as Result[string]: both the
+		// base type and the argument may need qualification.
+		return &ast.IndexExpr{X: ct.rewriteExpr(from, x.X), Index: ct.rewriteExpr(from, x.Index)}
+	case *ast.IndexListExpr:
+		out := &ast.IndexListExpr{X: ct.rewriteExpr(from, x.X)}
+		for _, idx := range x.Indices {
+			out.Indices = append(out.Indices, ct.rewriteExpr(from, idx))
+		}
+		return out
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: ct.rewriteExpr(from, x.Len), Elt: ct.rewriteExpr(from, x.Elt)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: ct.rewriteExpr(from, x.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: ct.rewriteExpr(from, x.Key), Value: ct.rewriteExpr(from, x.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: x.Dir, Value: ct.rewriteExpr(from, x.Value)}
+	case *ast.ParenExpr:
+		return &ast.ParenExpr{X: ct.rewriteExpr(from, x.X)}
+	case *ast.BasicLit:
+		return &ast.BasicLit{Kind: x.Kind, Value: x.Value}
+	case *ast.FuncType:
+		return &ast.FuncType{
+			Params:  ct.rewriteFieldList(from, x.Params),
+			Results: ct.rewriteFieldList(from, x.Results),
+		}
+	case *ast.StructType:
+		return &ast.StructType{Fields: ct.rewriteFieldList(from, x.Fields)}
+	case *ast.InterfaceType:
+		return &ast.InterfaceType{Methods: ct.rewriteFieldList(from, x.Methods)}
+	}
+	return x // unreachable for well-formed type expressions
+}
+
+func (ct *concreteType) rewriteFieldList(from *loader.PackageInfo, fl *ast.FieldList) *ast.FieldList {
+	if fl == nil {
+		return nil
+	}
+	out := &ast.FieldList{}
+	for _, f := range fl.List {
+		nf := &ast.Field{Type: ct.rewriteExpr(from, f.Type)}
+		for _, n := range f.Names {
+			nf.Names = append(nf.Names, ast.NewIdent(n.Name))
+		}
+		out.List = append(out.List, nf)
+	}
+	return out
+}
+
+// mightRenameSelector returns the name by which the package denoted by
+// pkgName is, or will be, known in the concrete file.  The concrete
+// file may import the package under a different name than the
+// interface's file does, in which case the selector must be renamed.
+// It consults both the file's existing imports and the imports added
+// earlier in this generation run, so that every method generated in one
+// run qualifies the same package the same way.
+func (ct *concreteType) mightRenameSelector(pkgName *types.PkgName) string {
+	return ct.packageName(pkgName.Imported())
+}
+
+// packageName returns the name by which pkg is, or will be, known in
+// the concrete file, adding an import if necessary.  The loader reports
+// a vendored package under its full /vendor/ path while source files
+// import it by its canonical path, so the path is devendorized before
+// any comparison; the import added, if any, is likewise canonical.
+func (ct *concreteType) packageName(pkg *types.Package) string {
+	ipath := ct.canonicalPath(imports.VendorlessPath(pkg.Path()))
+	if name, ok := fileImports(ct.file)[ipath]; ok {
+		if name == "" {
+			return pkg.Name()
+		}
+		return name
+	}
+	if name, ok := ct.addedImports[ipath]; ok {
+		return name
+	}
+	return ct.importName(ipath, pkg.Name())
+}
+
+// fileImports returns the file's imports as a map from import path to
+// local name; the name is "" for a default import.  Blank and dot
+// imports are omitted since they provide no usable qualifier.
+func fileImports(f *ast.File) map[string]string {
+	m := make(map[string]string)
+	for _, spec := range f.Imports {
+		ipath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+			if name == "_" || name == "." {
+				continue
+			}
+		}
+		m[ipath] = name
+	}
+	return m
+}
+
+// canonicalPath maps a loaded package path to the path the file should
+// import it by.  Under a module replace directive pointing at a local
+// copy, packages load under the on-disk path; importing that would not
+// compile anywhere else, so clients supply the canonical module path
+// through Request.CanonicalPaths.
+func (ct *concreteType) canonicalPath(ipath string) string {
+	if canon, ok := ct.canonicalPaths[ipath]; ok {
+		return canon
+	}
+	return ipath
+}
+
+// importName chooses a local name for a new import of the given path,
+// records it in addedImports, and returns it.  If the package's name is
+// already taken by another import, a numeric suffix is appended.
+func (ct *concreteType) importName(ipath, base string) string {
+	if name, ok := ct.addedImports[ipath]; ok {
+		return name
+	}
+	name := base
+	for i := 2; ct.nameInUse(name); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	ct.addedImports[ipath] = name
+	ct.pkgNames[ipath] = base
+	return name
+}
+
+// nameInUse reports whether name is already the local name of an import
+// in the concrete file, of an import added during this run, or of a
+// package-level identifier in the concrete package; an import under
+// such a name would shadow the identifier for the whole file, so a
+// fresh alias is chosen instead.
+func (ct *concreteType) nameInUse(name string) bool {
+	if ct.pkg.Pkg.Scope().Lookup(name) != nil {
+		return true
+	}
+	for ipath, local := range fileImports(ct.file) {
+		if local == name || (local == "" && path.Base(ipath) == name) {
+			return true
+		}
+	}
+	for _, local := range ct.addedImports {
+		if local == name {
+			return true
+		}
+	}
+	return false
+}
+
+// prevailingDocStyle reports whether the file's existing methods of
+// the concrete type all carry doc comments, in which case generated
+// stubs should too.  A type with no methods in the file has no
+// prevailing style.
+func (ct *concreteType) prevailingDocStyle() bool {
+	fields := strings.Fields(ct.recv)
+	name := strings.TrimPrefix(fields[len(fields)-1], "*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i] // a generic receiver's parameters are not part of the name
+	}
+	found := false
+	for _, decl := range ct.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+			continue
+		}
+		t := fn.Recv.List[0].Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		switch x := t.(type) {
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		}
+		if id, ok := t.(*ast.Ident); !ok || id.Name != name {
+			continue
+		}
+		if fn.Doc == nil {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// A methodData is the value a method template is executed with, one
+// per generated method.
+type methodData struct {
+	Recv      string   // the full receiver, e.g. "f *File"
+	RecvName  string   // the receiver's name alone, e.g. "f"
+	Name      string   // the method's name
+	Signature string   // parameters and results, e.g. "(p []byte) (n int, err error)"
+	Params    []string // the parameter names, in declaration order
+	Results   []string // the result types, in declaration order
+	Call      string   // delegating call, e.g. "s.Reader.Read(p)"; set only when forwarding
+	Return    string   // zero-value return statement; set only when Request.ZeroBody
+	TODO      string   // a problem with the method's signature, e.g. an inaccessible type
+	Doc       string   // doc comment line; set only when Request.MatchDocStyle applies
+}
+
+// defaultTemplate renders a method as a panicking stub.
+var defaultTemplate = template.Must(template.New("method").Parse(`
+{{if .Doc}}{{.Doc}}
+{{end}}func ({{.Recv}}) {{.Name}}{{.Signature}} {
+{{if .TODO}}	// TODO: {{.TODO}}
+{{end}}	panic("not implemented") // TODO: Implement
+}
+`))
+
+// markTODOTemplate renders a stub whose comment and panic message both
+// name the method, for grep-based and CI visibility.
+var markTODOTemplate = template.Must(template.New("marktodo").Parse(`
+{{if .Doc}}{{.Doc}}
+{{end}}func ({{.Recv}}) {{.Name}}{{.Signature}} {
+	// TODO(impl): implement {{.Name}}
+	panic("TODO: implement {{.Name}}")
+}
+`))
+
+// zeroTemplate renders a method whose body returns the zero value of
+// each result; a method with no results gets an empty body.
+var zeroTemplate = template.Must(template.New("zero").Parse(`
+{{if .Doc}}{{.Doc}}
+{{end}}func ({{.Recv}}) {{.Name}}{{.Signature}} {
+{{if .Return}}	{{.Return}}
+{{end}}}
+`))
+
+// forwardTemplate renders a method that delegates to an embedded
+// interface field.
+var forwardTemplate = template.Must(template.New("forward").Parse(`
+{{if .Doc}}{{.Doc}}
+{{end}}func ({{.Recv}}) {{.Name}}{{.Signature}} {
+	{{if .Results}}return {{end}}{{.Call}}
+}
+`))
+
+// renderStubs renders the method declarations through the template,
+// unformatted.  If tmpl is nil, defaultTemplate is used.
+func (ct *concreteType) renderStubs(methods []*method, tmpl *template.Template) ([]byte, error) {
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+	var stubs bytes.Buffer
+	if ct.region != "" {
+		fmt.Fprintf(&stubs, "\n// impl:begin %s\n", ct.region)
+	}
+	stubFset := token.NewFileSet()
+	for _, m := range methods {
+		var sig bytes.Buffer
+		if err := printer.Fprint(&sig, stubFset, m.sig); err != nil {
+			return nil, err
+		}
+		data := methodData{
+			Recv:      ct.recv,
+			RecvName:  strings.Fields(ct.recv)[0],
+			Name:      m.name,
+			Signature: strings.TrimPrefix(sig.String(), "func"),
+		}
+		if m.sig.Params != nil {
+			for _, f := range m.sig.Params.List {
+				for _, n := range f.Names {
+					data.Params = append(data.Params, n.Name)
+				}
+			}
+		}
+		if m.sig.Results != nil {
+			for _, f := range m.sig.Results.List {
+				var typ bytes.Buffer
+				if err := printer.Fprint(&typ, stubFset, f.Type); err != nil {
+					return nil, err
+				}
+				n := len(f.Names)
+				if n == 0 {
+					n = 1
+				}
+				for i := 0; i < n; i++ {
+					data.Results = append(data.Results, typ.String())
+				}
+			}
+		}
+		data.TODO = m.todo
+		if ct.docStubs {
+			data.Doc = fmt.Sprintf("// %s ...", m.name)
+		}
+		if ct.zeroBody && m.typ != nil {
+			data.Return = ct.zeroReturn(m.name, m.typ)
+		}
+		if ct.forwardField != "" {
+			args := append([]string(nil), data.Params...)
+			if n := len(args); n > 0 && m.sig.Params != nil {
+				last := m.sig.Params.List[len(m.sig.Params.List)-1]
+				if _, variadic := last.Type.(*ast.Ellipsis); variadic {
+					args[n-1] += "..."
+				}
+			}
+			data.Call = fmt.Sprintf("%s.%s.%s(%s)",
+				data.RecvName, ct.forwardField, m.name, strings.Join(args, ", "))
+		}
+		use := tmpl
+		if ct.strategies[m.name] == StrategyForward {
+			use = forwardTemplate
+		}
+		if err := use.Execute(&stubs, data); err != nil {
+			return nil, fmt.Errorf("executing method template: %v", err)
+		}
+	}
+	stubs.WriteString(ct.sentinelDecl)
+	stubs.WriteString(ct.registration)
+	if ct.region != "" {
+		fmt.Fprintf(&stubs, "\n// impl:end %s\n", ct.region)
+	}
+	return stubs.Bytes(), nil
+}
+
+// A registrationData is the value a Request.Registration template is
+// executed with.
+type registrationData struct {
+	Type   string // the concrete type's name, e.g. "File"
+	Target string // the receiver's type as targeted, e.g. "*File"
+}
+
+// checkFieldCollisions rejects generation when a method to generate
+// shares its name with a field of the concrete struct: the spec
+// forbids a field and method of the same name, so the stub could
+// never compile.
+func (ct *concreteType) checkFieldCollisions(methods []*method) error {
+	st, ok := ct.typ.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	fields := make(map[string]bool, st.NumFields())
+	for i := 0; i < st.NumFields(); i++ {
+		fields[st.Field(i).Name()] = true
+	}
+	tname := "the concrete type"
+	if named, ok := ct.typ.(*types.Named); ok {
+		tname = named.Obj().Name()
+	}
+	for _, m := range methods {
+		if fields[m.name] {
+			return fmt.Errorf("cannot generate method %s: %s already has a field named %s", m.name, tname, m.name)
+		}
+	}
+	return nil
+}
+
+// conversionCandidates returns the package's top-level functions whose
+// signature matches a missing method once the receiver is taken as the
+// function's first parameter.  Such a function can be converted into
+// the method instead of writing its body again behind a stub.
+func (ct *concreteType) conversionCandidates(methods []*method) []*Conversion {
+	recv := ct.typ
+	if fields := strings.SplitN(ct.recv, " ", 2); len(fields) == 2 && strings.HasPrefix(fields[1], "*") {
+		recv = types.NewPointer(ct.typ)
+	}
+	var convs []*Conversion
+	scope := ct.pkg.Pkg.Scope()
+	for _, m := range methods {
+		if m.typ == nil {
+			continue
+		}
+		for _, name := range scope.Names() {
+			fn, ok := scope.Lookup(name).(*types.Func)
+			if !ok {
+				continue
+			}
+			if convertible(fn.Type().(*types.Signature), recv, m.typ) {
+				convs = append(convs, &Conversion{
+					Func:   name,
+					Method: m.name,
+					Title:  fmt.Sprintf("Convert %s to method %s", name, m.name),
+				})
+			}
+		}
+	}
+	return convs
+}
+
+// convertible reports whether a function with signature fn could become
+// a method with signature want on the receiver type recv: its first
+// parameter has the receiver's type and the remaining parameters and
+// the results match exactly.
+func convertible(fn *types.Signature, recv types.Type, want *types.Signature) bool {
+	if fn.Params().Len() != want.Params().Len()+1 || fn.Variadic() != want.Variadic() {
+		return false
+	}
+	if !types.Identical(fn.Params().At(0).Type(), recv) {
+		return false
+	}
+	for i := 0; i < want.Params().Len(); i++ {
+		if !types.Identical(fn.Params().At(i+1).Type(), want.Params().At(i).Type()) {
+			return false
+		}
+	}
+	return types.Identical(fn.Results(), want.Results())
+}
+
+// embedInterface prepends an anonymous field of the interface type to
+// the concrete struct's declaration, so the methods that are not
+// explicitly overridden are satisfied by promotion.
+func (ct *concreteType) embedInterface(it *ifaceType) error {
+	named, ok := ct.typ.(*types.Named)
+	if !ok {
+		return fmt.Errorf("cannot embed an interface in an unnamed type")
+	}
+	tname := named.Obj().Name()
+	var st *ast.StructType
+	for _, decl := range ct.file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == tname {
+				st, ok = ts.Type.(*ast.StructType)
+				if !ok {
+					return fmt.Errorf("cannot embed an interface in %s: not a struct type", tname)
+				}
+			}
+		}
+	}
+	if st == nil {
+		return fmt.Errorf("declaration of %s not found in the target file", tname)
+	}
+
+	var typ ast.Expr = ast.NewIdent(it.name)
+	if it.pkg.Pkg != ct.pkg.Pkg {
+		name := ct.importName(ct.canonicalPath(imports.VendorlessPath(it.pkg.Pkg.Path())), it.pkg.Pkg.Name())
+		typ = &ast.SelectorExpr{X: ast.NewIdent(name), Sel: ast.NewIdent(it.name)}
+	}
+	st.Fields.List = append([]*ast.Field{{Type: typ}}, st.Fields.List...)
+	return nil
+}
+
+// zeroReturn builds the return statement for a zero-value method body:
+// a bare return when the results are named, "" when there are none,
+// and otherwise one zero expression per result, in declaration order.
+// When an error package is configured and the last result is an error,
+// that position gets a NotImplemented call naming the method instead of
+// nil.
+func (ct *concreteType) zeroReturn(name string, sig *types.Signature) string {
+	results := sig.Results()
+	if results.Len() == 0 {
+		return ""
+	}
+	n := results.Len()
+	if ct.sentinel != "" && types.Identical(results.At(n-1).Type(), types.Universe.Lookup("error").Type()) {
+		parts := make([]string, n)
+		for i := 0; i < n-1; i++ {
+			parts[i] = ct.zeroExpr(results.At(i).Type())
+		}
+		parts[n-1] = ct.sentinel
+		return "return " + strings.Join(parts, ", ")
+	}
+	if ct.errPkg != "" && types.Identical(results.At(n-1).Type(), types.Universe.Lookup("error").Type()) {
+		parts := make([]string, n)
+		for i := 0; i < n-1; i++ {
+			parts[i] = ct.zeroExpr(results.At(i).Type())
+		}
+		parts[n-1] = fmt.Sprintf("%s.NotImplemented(%q)", ct.errorPackageName(), name)
+		return "return " + strings.Join(parts, ", ")
+	}
+	if results.At(0).Name() != "" {
+		return "return"
+	}
+	zeros := make([]string, n)
+	for i := range zeros {
+		zeros[i] = ct.zeroExpr(results.At(i).Type())
+	}
+	return "return " + strings.Join(zeros, ", ")
+}
+
+// useSentinel arranges for zero-value bodies to return the named
+// package-level error variable in a trailing error position.  When the
+// variable does not exist yet, its declaration is scheduled to follow
+// the generated methods; a name that exists but is not an error
+// variable is rejected.
+func (ct *concreteType) useSentinel(name string) (created bool, err error) {
+	ct.sentinel = name
+	obj := ct.pkg.Pkg.Scope().Lookup(name)
+	if obj == nil {
+		ct.importName("errors", "errors")
+		ct.sentinelDecl = fmt.Sprintf("\nvar %s = errors.New(\"not implemented\")\n", name)
+		return true, nil
+	}
+	v, ok := obj.(*types.Var)
+	if !ok || !types.AssignableTo(v.Type(), types.Universe.Lookup("error").Type()) {
+		return false, fmt.Errorf("%s is not a package-level error variable", name)
+	}
+	return false, nil
+}
+
+// errorPackageName returns the local name by which the configured error
+// helper package is, or will be, known in the concrete file, adding an
+// import if necessary.  The package is not loaded, so its declared name
+// is assumed to be the last element of its import path.
+func (ct *concreteType) errorPackageName() string {
+	if name, ok := fileImports(ct.file)[ct.errPkg]; ok {
+		if name == "" {
+			return path.Base(ct.errPkg)
+		}
+		return name
+	}
+	return ct.importName(ct.errPkg, path.Base(ct.errPkg))
+}
+
+// zeroExpr renders the zero value of a type as an expression valid in
+// the concrete file, adding imports for any packages it mentions.
+func (ct *concreteType) zeroExpr(t types.Type) string {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Info()&types.IsBoolean != 0:
+			return "false"
+		case u.Info()&types.IsString != 0:
+			return `""`
+		case u.Info()&types.IsNumeric != 0:
+			return "0"
+		}
+	case *types.Struct, *types.Array:
+		return types.TypeString(t, func(pkg *types.Package) string {
+			if pkg == ct.pkg.Pkg {
+				return ""
+			}
+			return ct.packageName(pkg)
+		}) + "{}"
+	}
+	return "nil"
+}
+
+// sourceWithStubs renders the concrete file with the stub methods and
+// any imports they need appended, formatted in gofmt style, along with
+// the stubs on their own.  If tmpl is nil, defaultTemplate is used.
+func (ct *concreteType) sourceWithStubs(methods []*method, tmpl *template.Template) (*Response, error) {
+	stubs, err := ct.renderStubs(methods, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	methodsOut, err := format.Source(bytes.TrimSpace(stubs))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, ct.prog.Fset, ct.file); err != nil {
+		return nil, err
+	}
+	src := buf.Bytes()
+	if ct.region != "" {
+		src = stripRegion(src, ct.region)
+	}
+	src = append(src, stubs...)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for ipath := range ct.addedImports {
+		paths = append(paths, ipath)
+	}
+	sort.Strings(paths)
+	for _, ipath := range paths {
+		// As goimports does, omit the local name whenever it matches
+		// the package's declared name, even if that differs from the
+		// import path's last element; the compiler resolves the
+		// qualifier from the declared name.
+		name := ct.addedImports[ipath]
+		if name == ct.pkgNames[ipath] {
+			astutil.AddImport(fset, file, ipath)
+		} else {
+			astutil.AddNamedImport(fset, file, name, ipath)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, file); err != nil {
+		return nil, err
+	}
+	return &Response{File: out.Bytes(), Methods: methodsOut, Imports: ct.addedImportsList()}, nil
+}
+
+// retargetNewFile validates the requested package clause and swaps the
+// concrete file for an empty one, so that every qualification made from
+// here on registers the import the new file will need.
+func (ct *concreteType) retargetNewFile(req *Request) error {
+	pkgName := ct.pkg.Pkg.Name()
+	clause := req.TargetPackage
+	if clause == "" {
+		clause = pkgName
+	}
+	if clause != pkgName && clause != pkgName+"_test" {
+		return fmt.Errorf("target package %q does not match package %q", clause, pkgName)
+	}
+	ct.file = &ast.File{Name: ast.NewIdent(clause)}
+	ct.targetPkg = clause
+	if clause != pkgName {
+		// An external test file is outside the package proper and must
+		// import the package under test to reach its identifiers.
+		ct.importName(req.ConcretePath, pkgName)
+	}
+	return nil
+}
+
+// newFileStubs renders the stubs as the complete contents of a new
+// file.  The concrete file's imports are deliberately not consulted:
+// the new file must import everything its declarations mention.
+func (ct *concreteType) newFileStubs(methods []*method, tmpl *template.Template) (*Response, error) {
+	clause := ct.targetPkg
+	stubs, err := ct.renderStubs(methods, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	methodsOut, err := format.Source(bytes.TrimSpace(stubs))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n", clause)
+	buf.Write(stubs)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for ipath := range ct.addedImports {
+		paths = append(paths, ipath)
+	}
+	sort.Strings(paths)
+	for _, ipath := range paths {
+		name := ct.addedImports[ipath]
+		if name == ct.pkgNames[ipath] {
+			astutil.AddImport(fset, file, ipath)
+		} else {
+			astutil.AddNamedImport(fset, file, name, ipath)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, file); err != nil {
+		return nil, err
+	}
+	return &Response{File: out.Bytes(), Methods: methodsOut, Imports: ct.addedImportsList()}, nil
+}
+
+// addedImportsList returns the imports added during rendering, sorted
+// by path for deterministic output.
+func (ct *concreteType) addedImportsList() []*AddedImport {
+	var imps []*AddedImport
+	for ipath, name := range ct.addedImports {
+		imps = append(imps, &AddedImport{Name: name, Path: ipath})
+	}
+	sort.Slice(imps, func(i, j int) bool { return imps[i].Path < imps[j].Path })
+	return imps
+}
+
+// granularStubs is the fast path used by Request.Granular: the
+// formatted methods block and any import statement are produced as
+// discrete edits spliced into the original contents, so the file is
+// never reparsed or reformatted and untouched regions stay
+// byte-identical.
+func (ct *concreteType) granularStubs(orig []byte, methods []*method, tmpl *template.Template) (*Response, error) {
+	stubs, err := ct.renderStubs(methods, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	methodsOut, err := format.Source(bytes.TrimSpace(stubs))
+	if err != nil {
+		return nil, err
+	}
+
+	var edits []Edit
+	if len(ct.addedImports) > 0 {
+		var b bytes.Buffer
+		b.WriteString("\n\nimport (\n")
+		var paths []string
+		for ipath := range ct.addedImports {
+			paths = append(paths, ipath)
+		}
+		sort.Strings(paths)
+		for _, ipath := range paths {
+			if name := ct.addedImports[ipath]; name != ct.pkgNames[ipath] {
+				fmt.Fprintf(&b, "\t%s %q\n", name, ipath)
+			} else {
+				fmt.Fprintf(&b, "\t%q\n", ipath)
+			}
+		}
+		b.WriteString(")")
+		off := ct.importInsertOffset()
+		if off < 0 || off > len(orig) {
+			return nil, fmt.Errorf("import insertion offset %d outside file of %d bytes", off, len(orig))
+		}
+		edits = append(edits, Edit{Start: off, End: off, Text: b.String()})
+	}
+	insertAt := ct.methodInsertOffset(orig)
+	if len(methodsOut) > 0 {
+		edits = append(edits, Edit{Start: insertAt, End: insertAt, Text: "\n" + string(methodsOut) + "\n"})
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start < edits[j].Start })
+
+	// A miscalculated offset would land a splice inside a token and
+	// produce invalid Go; parse the assembled contents so the failure
+	// is caught here, with context, rather than when the caller applies
+	// the edits.
+	assembled := applyEdits(orig, edits)
+	if _, err := parser.ParseFile(token.NewFileSet(), "", assembled, 0); err != nil {
+		off := len(orig)
+		if len(edits) > 0 {
+			off = edits[0].Start
+		}
+		return nil, fmt.Errorf("spliced stubs do not parse: %v; around the insertion point: %q",
+			err, snippet(assembled, off, 40))
+	}
+
+	return &Response{
+		File:    assembled,
+		Methods: methodsOut,
+		Imports: ct.addedImportsList(),
+		Offset:  insertAt,
+		Edits:   edits,
+	}, nil
+}
+
+// methodInsertOffset returns the byte offset at which the methods
+// block is spliced: the start of the requested insertion line when
+// that falls on a boundary between top-level declarations, and the end
+// of the file — the default placement — when no line was requested or
+// the line is unusable.  A position inside a declaration, or before
+// the imports, where a function may not appear, falls back rather than
+// producing unparsable output.
+func (ct *concreteType) methodInsertOffset(orig []byte) int {
+	if ct.insertLine <= 0 {
+		return len(orig)
+	}
+	tf := ct.prog.Fset.File(ct.file.Pos())
+	if tf == nil || ct.insertLine > tf.LineCount() {
+		return len(orig)
+	}
+	off := ct.prog.Fset.Position(tf.LineStart(ct.insertLine)).Offset
+	if off > len(orig) || off < ct.importInsertOffset() {
+		return len(orig)
+	}
+	for _, decl := range ct.file.Decls {
+		start := ct.prog.Fset.Position(decl.Pos()).Offset
+		end := ct.prog.Fset.Position(decl.End()).Offset
+		if off > start && off < end {
+			return len(orig)
+		}
+	}
+	return off
+}
+
+// snippet returns the region of src within radius bytes of off, for
+// error messages.
+func snippet(src []byte, off, radius int) string {
+	lo := off - radius
+	if lo < 0 {
+		lo = 0
+	}
+	hi := off + radius
+	if hi > len(src) {
+		hi = len(src)
+	}
+	return string(src[lo:hi])
+}
+
+// importInsertOffset returns the byte offset at which a new import
+// statement can be inserted: after the last existing import
+// declaration, or after the package clause if there is none.
+func (ct *concreteType) importInsertOffset() int {
+	pos := ct.file.Name.End()
+	for _, decl := range ct.file.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			pos = gen.End()
+		}
+	}
+	return ct.prog.Fset.Position(pos).Offset
+}
+
+// applyEdits splices the edits, which must be sorted and
+// non-overlapping, into orig.
+func applyEdits(orig []byte, edits []Edit) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		out.Write(orig[last:e.Start])
+		out.WriteString(e.Text)
+		last = e.End
+	}
+	out.Write(orig[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build go1.24
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestGenericAliasConcrete targets a generic type alias (go1.24) as the
+// concrete type; the receiver must be spelled with the aliased type's
+// name and its own parameter names.
+func TestGenericAliasConcrete(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Cache[T any] struct{}
+
+type Store[T any] = Cache[T]`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "s *Store",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (s *Cache[T]) Run() error {") {
+		t.Errorf("receiver not resolved through the generic alias:\n%s", resp.File)
+	}
+}
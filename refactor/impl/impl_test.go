@@ -0,0 +1,2962 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+	"golang.org/x/tools/imports"
+)
+
+// fakeContext is a simplifying wrapper around buildutil.FakeContext for
+// packages whose filenames are sequentially numbered (%d.go).  pkgs
+// maps a package import path to its list of file contents.
+func fakeContext(pkgs map[string][]string) *build.Context {
+	pkgs2 := make(map[string]map[string]string)
+	for path, files := range pkgs {
+		filemap := make(map[string]string)
+		for i, contents := range files {
+			filemap[fmt.Sprintf("%d.go", i)] = contents
+		}
+		pkgs2[path] = filemap
+	}
+	return buildutil.FakeContext(pkgs2)
+}
+
+func TestImplement(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"io": {`package io
+
+type Reader interface {
+	Read(p []byte) (n int, err error)
+}
+
+type Closer interface {
+	Close() error
+}
+
+type ReadCloser interface {
+	Reader
+	Closer
+}`},
+		"conc": {`package conc
+
+type File struct{}
+
+func (f *File) Close() error { return nil }`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "io",
+		IfaceName:    "ReadCloser",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "f *File",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (f *File) Read(p []byte) (n int, err error) {") {
+		t.Errorf("missing Read stub in output:\n%s", resp.File)
+	}
+	if strings.Contains(string(resp.File), "func (f *File) Close() error {\n\tpanic") {
+		t.Errorf("Close stub generated for already-implemented method:\n%s", resp.File)
+	}
+	methods := string(resp.Methods)
+	if !strings.HasPrefix(methods, "func (f *File) Read(p []byte) (n int, err error) {") {
+		t.Errorf("Methods does not start with the Read stub:\n%s", methods)
+	}
+	if strings.Contains(methods, "package") {
+		t.Errorf("Methods should contain declarations only:\n%s", methods)
+	}
+}
+
+func TestOrderAlphabetically(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Zoo interface {
+	Walrus()
+	Ape()
+	Moose()
+	Ape2() // embedding re-declares Ape below
+	sub
+}
+
+type sub interface {
+	Ape()
+	Bee()
+}`},
+		"conc": {`package conc
+
+type Keeper struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:                ctxt,
+		IfacePath:           "iface",
+		IfaceName:           "Zoo",
+		ConcretePath:        "conc",
+		File:                "0.go",
+		Target:              "k *Keeper",
+		OrderAlphabetically: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	var idx []int
+	for _, name := range []string{"Ape()", "Ape2()", "Bee()", "Moose()", "Walrus()"} {
+		i := strings.Index(src, "func (k *Keeper) "+name)
+		if i < 0 {
+			t.Fatalf("missing stub for %s:\n%s", name, src)
+		}
+		idx = append(idx, i)
+	}
+	for i := 1; i < len(idx); i++ {
+		if idx[i] < idx[i-1] {
+			t.Errorf("methods not in alphabetical order:\n%s", src)
+			break
+		}
+	}
+	if strings.Count(src, "func (k *Keeper) Ape()") != 1 {
+		t.Errorf("duplicate Ape stub:\n%s", src)
+	}
+}
+
+func TestOrderOptionsExclusive(t *testing.T) {
+	_, err := Implement(&Request{OrderBySource: true, OrderAlphabetically: true})
+	if err == nil {
+		t.Fatal("expected error for mutually exclusive ordering options")
+	}
+}
+
+// TestCrossPackageAlias checks that an alias for a type defined in
+// another package is refused with a pointer to the defining package.
+func TestCrossPackageAlias(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"other": {`package other
+
+type Writer struct{}`},
+		"conc": {`package conc
+
+import "other"
+
+type LocalWriter = other.Writer`},
+	})
+
+	_, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "w *LocalWriter",
+	})
+	want := `LocalWriter is an alias for other.Writer; methods must be declared in package "other"`
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
+
+// TestRegistration checks that a registration snippet is rendered with
+// the type's data and appended after the stubs.
+func TestRegistration(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+func Register(name string, v interface{}) {}
+
+type Widget struct{}`},
+	})
+
+	req := &Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "w *Widget",
+		Registration: `func init() {
+	Register("{{.Type}}", &{{.Type}}{})
+}`,
+	}
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if want := "func init() {\n\tRegister(\"Widget\", &Widget{})\n}"; !strings.Contains(src, want) {
+		t.Errorf("output missing registration:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Widget) Run() error {") {
+		t.Errorf("output missing Run stub:\n%s", src)
+	}
+
+	req.Registration = "func init() {{.Oops"
+	if _, err := Implement(req); err == nil || !strings.Contains(err.Error(), "invalid registration template") {
+		t.Errorf("malformed snippet: error = %v, want parse failure", err)
+	}
+}
+
+// TestFieldCollision checks that a struct field sharing its name with
+// a required method is reported instead of generating uncompilable
+// code.
+func TestFieldCollision(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Closer interface {
+	Close() error
+}`},
+		"conc": {`package conc
+
+type Conn struct {
+	Close bool
+}`},
+	})
+
+	_, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Closer",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Conn",
+	})
+	want := "cannot generate method Close: Conn already has a field named Close"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
+
+// TestMarkTODO checks that MarkTODO bodies name the method in both the
+// comment and the panic message.
+func TestMarkTODO(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Job struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+		MarkTODO:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "func (j *Job) Run() error {\n\t// TODO(impl): implement Run\n\tpanic(\"TODO: implement Run\")\n}"
+	if !strings.Contains(string(resp.File), want) {
+		t.Errorf("output missing %q:\n%s", want, resp.File)
+	}
+
+	if _, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+		MarkTODO:     true,
+		ZeroBody:     true,
+	}); err == nil {
+		t.Error("MarkTODO with ZeroBody succeeded, want error")
+	}
+}
+
+// TestGenericConcreteType checks that a generic concrete type gets its
+// receiver rendered with the declared type parameters.
+func TestGenericConcreteType(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Resetter interface {
+	Reset() error
+}`},
+		"conc": {`package conc
+
+type Cache[K comparable, V any] struct {
+	m map[K]V
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Resetter",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Cache[int, string]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func (c *Cache[K, V]) Reset() error {"; !strings.Contains(string(resp.File), want) {
+		t.Errorf("output missing %q:\n%s", want, resp.File)
+	}
+}
+
+// TestEmbedInterface checks the forward-compatible pattern: the
+// interface is embedded in the struct and only the requested override
+// is generated.
+func TestEmbedInterface(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Conn interface {
+	Read(p []byte) (int, error)
+	Write(p []byte) (int, error)
+	Close() error
+}`},
+		"conc": {`package conc
+
+type Proxy struct {
+	addr string
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:           ctxt,
+		IfacePath:      "iface",
+		IfaceName:      "Conn",
+		ConcretePath:   "conc",
+		File:           "0.go",
+		Target:         "p *Proxy",
+		EmbedInterface: true,
+		Overrides:      []string{"Close"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if want := "type Proxy struct {\n\tiface.Conn\n\taddr string\n}"; !strings.Contains(src, want) {
+		t.Errorf("struct not rewritten to embed the interface:\n%s", src)
+	}
+	if !strings.Contains(src, "func (p *Proxy) Close() error {") {
+		t.Errorf("missing Close override:\n%s", src)
+	}
+	for _, absent := range []string{"func (p *Proxy) Read", "func (p *Proxy) Write"} {
+		if strings.Contains(src, absent) {
+			t.Errorf("%s generated despite the embedded interface:\n%s", absent, src)
+		}
+	}
+}
+
+// TestGenericEmbedding checks that an embedded instantiation of a
+// generic interface contributes its methods with the type arguments
+// substituted.
+func TestGenericEmbedding(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Reader[T any] interface {
+	Read() (T, error)
+	Len() int
+}
+
+type ByteSource interface {
+	Reader[byte]
+	Close() error
+}`},
+		"conc": {`package conc
+
+type Buf struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "ByteSource",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "b *Buf",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"func (b *Buf) Read() (byte, error) {",
+		"func (b *Buf) Len() int {",
+		"func (b *Buf) Close() error {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "(T, error)") {
+		t.Errorf("type parameter escaped unsubstituted:\n%s", src)
+	}
+}
+
+// TestNamedPointerReceiver checks that a concrete type whose underlying
+// type cannot carry methods is rejected with a specific error.
+func TestNamedPointerReceiver(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type node struct{}
+
+type List *node
+
+type Alias = []int`},
+	})
+
+	_, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "l *List",
+	})
+	if err == nil || err.Error() != "cannot define methods on named pointer type List" {
+		t.Errorf("List: error = %v, want %q", err, "cannot define methods on named pointer type List")
+	}
+
+	_, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "a Alias",
+	})
+	if err == nil || !strings.Contains(err.Error(), "not a defined type") {
+		t.Errorf("Alias: error = %v, want a not-a-defined-type error", err)
+	}
+}
+
+// TestInaccessibleType checks that an interface method referencing an
+// unexported type from another package still generates, flagged with a
+// TODO at the offending method, while the other methods come out clean.
+func TestInaccessibleType(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type secret struct{}
+
+type Vault interface {
+	Open() error
+	Peek() secret
+	Close() error
+}`},
+		"conc": {`package conc
+
+type Box struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Vault",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "b *Box",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if want := "func (b *Box) Peek() iface.secret {\n\t// TODO: unexported type iface.secret is not accessible here\n"; !strings.Contains(src, want) {
+		t.Errorf("output missing %q:\n%s", want, src)
+	}
+	for _, clean := range []string{"Open() error", "Close() error"} {
+		i := strings.Index(src, clean)
+		if i < 0 {
+			t.Fatalf("output missing %s:\n%s", clean, src)
+		}
+		if body := src[i:]; strings.Contains(body[:strings.Index(body, "}")], "not accessible") {
+			t.Errorf("%s flagged with a TODO it does not deserve:\n%s", clean, src)
+		}
+	}
+	found := false
+	for _, n := range resp.Notes {
+		if n == "method Peek: unexported type iface.secret is not accessible here" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("notes missing the inaccessible-type warning: %q", resp.Notes)
+	}
+}
+
+// TestOrganizeImports checks that the result of OrganizeImports is a
+// fixed point: organizing imports over it again changes nothing, so
+// editors that organize on save see no follow-up churn.
+func TestOrganizeImports(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"io": {`package io
+
+type Reader interface {
+	Read(p []byte) (n int, err error)
+}`},
+		"strings": {`package strings
+
+type Builder struct{}`},
+		"conc": {`package conc
+
+import "strings"
+
+type File struct{ name strings.Builder }`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:            ctxt,
+		IfacePath:       "io",
+		IfaceName:       "Reader",
+		ConcretePath:    "conc",
+		File:            "0.go",
+		Target:          "f *File",
+		OrganizeImports: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := imports.Process("0.go", resp.File, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, resp.File) {
+		t.Errorf("organizing imports again produced further edits:\n--- first\n%s\n--- second\n%s", resp.File, again)
+	}
+	if !strings.Contains(string(resp.File), "func (f *File) Read(p []byte) (n int, err error) {") {
+		t.Errorf("missing Read stub:\n%s", resp.File)
+	}
+}
+
+// TestCompositeTypeQualification checks that package references nested
+// inside channel, map, slice, and pointer types are qualified and their
+// imports added.
+func TestCompositeTypeQualification(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}
+type Key string
+type Value struct{}
+type Thing struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Source interface {
+	Events() <-chan events.Event
+	Index() map[events.Key][]events.Value
+	Thing() **events.Thing
+}`},
+		"conc": {`package conc
+
+type Hub struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Source",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "h *Hub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		`import "events"`,
+		"func (h *Hub) Events() <-chan events.Event {",
+		"func (h *Hub) Index() map[events.Key][]events.Value {",
+		"func (h *Hub) Thing() **events.Thing {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing %q:\n%s", want, src)
+		}
+	}
+}
+
+// TestZeroBody checks the zero-value bodies: one zero expression per
+// result in declaration order, a bare return for named results, and an
+// empty body when there are no results.
+func TestZeroBody(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Value struct{}
+
+type Store interface {
+	Load(key string) (Value, error)
+	Stat() (n int, err error)
+	Reset()
+	Count() int
+}`},
+		"conc": {`package conc
+
+type Mem struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Store",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "m *Mem",
+		ZeroBody:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"func (m *Mem) Load(key string) (iface.Value, error) {\n\treturn iface.Value{}, nil\n}",
+		"func (m *Mem) Stat() (n int, err error) {\n\treturn\n}",
+		"func (m *Mem) Reset() {\n}",
+		"func (m *Mem) Count() int {\n\treturn 0\n}",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing %q:\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "panic(") {
+		t.Errorf("ZeroBody output should not panic:\n%s", src)
+	}
+}
+
+// TestUnexportedMethods checks that an interface with an unexported
+// method can be implemented within its own package but is rejected,
+// with a clear error, from any other package.
+func TestUnexportedMethods(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type sealed interface {
+	Public() error
+	private() int
+}
+
+type Sealed = sealed
+
+type Same struct{}`},
+		"conc": {`package conc
+
+type Other struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "sealed",
+		ConcretePath: "iface",
+		File:         "0.go",
+		Target:       "s *Same",
+	})
+	if err != nil {
+		t.Fatalf("same-package: %v", err)
+	}
+	for _, want := range []string{
+		"func (s *Same) Public() error {",
+		"func (s *Same) private() int {",
+	} {
+		if !strings.Contains(string(resp.File), want) {
+			t.Errorf("same-package output missing %q:\n%s", want, resp.File)
+		}
+	}
+
+	_, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "sealed",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "o *Other",
+	})
+	if err == nil {
+		t.Fatal("cross-package: expected error for unexported method, got none")
+	}
+	want := `interface sealed has unexported method private and cannot be implemented outside package "iface"`
+	if err.Error() != want {
+		t.Errorf("cross-package error = %q, want %q", err, want)
+	}
+}
+
+// TestGranular checks that the granular path leaves untouched regions
+// byte-identical, even ones gofmt would rewrite.
+func TestGranular(t *testing.T) {
+	orig := "package conc\n\nvar   ugly    = 1 // gofmt would collapse these spaces\n\ntype T struct{}\n"
+	ctxt := fakeContext(map[string][]string{
+		"time": {`package time
+
+type Duration int64`},
+		"iface": {`package iface
+
+import "time"
+
+type Waiter interface {
+	Wait(d time.Duration) error
+}`},
+		"conc": {orig},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Waiter",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "t *T",
+		Granular:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "var   ugly    = 1") {
+		t.Errorf("untouched region was reformatted:\n%s", src)
+	}
+	if !strings.Contains(src, "import (\n\t\"time\"\n)") {
+		t.Errorf("import statement not inserted:\n%s", src)
+	}
+	if !strings.Contains(src, "func (t *T) Wait(d time.Duration) error {") {
+		t.Errorf("missing Wait stub:\n%s", src)
+	}
+	if got := string(applyEdits([]byte(orig), resp.Edits)); got != src {
+		t.Errorf("edits do not reproduce File:\n%s", got)
+	}
+}
+
+// BenchmarkManyEmbedded stresses embedded-interface resolution: fifty
+// methods spread over ten files, each embedding a shared interface so
+// the same declarations are resolved repeatedly.
+func BenchmarkManyEmbedded(b *testing.B) {
+	files := []string{`package iface
+
+type Shared interface {
+	Shared() error
+}
+
+type Big interface {
+	M0
+	M1
+	M2
+	M3
+	M4
+	M5
+	M6
+	M7
+	M8
+	M9
+}`}
+	for i := 0; i < 10; i++ {
+		var f strings.Builder
+		fmt.Fprintf(&f, "package iface\n\ntype M%d interface {\n\tShared\n", i)
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&f, "\tMethod%d_%d() error\n", i, j)
+		}
+		f.WriteString("}\n")
+		files = append(files, f.String())
+	}
+	ctxt := fakeContext(map[string][]string{
+		"iface": files,
+		"conc":  {"package conc\n\ntype T struct{}\n"},
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Implement(&Request{
+			Ctxt:         ctxt,
+			IfacePath:    "iface",
+			IfaceName:    "Big",
+			ConcretePath: "conc",
+			File:         "0.go",
+			Target:       "t *T",
+		}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkImplement(b *testing.B) {
+	// A large concrete file: the granular path should not pay to
+	// reformat it.
+	var big strings.Builder
+	big.WriteString("package conc\n\ntype T struct{}\n")
+	for i := 0; i < 2500; i++ {
+		fmt.Fprintf(&big, "\nfunc helper%d() int {\n\treturn %d\n}\n", i, i)
+	}
+	ctxt := fakeContext(map[string][]string{
+		"iface": {"package iface\n\ntype I interface {\n\tM() int\n}"},
+		"conc":  {big.String()},
+	})
+	for _, granular := range []bool{false, true} {
+		name := "full"
+		if granular {
+			name = "granular"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Implement(&Request{
+					Ctxt:         ctxt,
+					IfacePath:    "iface",
+					IfaceName:    "I",
+					ConcretePath: "conc",
+					File:         "0.go",
+					Target:       "t *T",
+					Granular:     granular,
+				}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestTypeTermEmbeddings checks that type terms embedded alongside
+// methods are ignored for stubbing and that a pure constraint is
+// rejected with a clear error.
+func TestTypeTermEmbeddings(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Mixed interface {
+	~[]int | ~string
+	Len() int
+}
+
+type Constraint interface {
+	~int | ~float64
+}`},
+		"conc": {`package conc
+
+type List struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Mixed",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "l *List",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (l *List) Len() int {") {
+		t.Errorf("missing Len stub:\n%s", src)
+	}
+	if strings.Contains(src, "~") {
+		t.Errorf("type terms leaked into the output:\n%s", src)
+	}
+
+	_, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Constraint",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "l *List",
+	})
+	if err == nil || !strings.Contains(err.Error(), "type constraint") {
+		t.Errorf("expected type constraint error, got %v", err)
+	}
+}
+
+// TestTwoInterfacesSharedDependency implements two interfaces that
+// both reference the same dependency package, reusing one Request
+// value, and checks that neither run contaminates the other: the
+// interface is always passed explicitly through the traversal rather
+// than read from shared mutable state.
+func TestTwoInterfacesSharedDependency(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"dep": {`package dep
+
+type T struct{}`},
+		"iface": {`package iface
+
+import "dep"
+
+type Producer interface {
+	Produce() dep.T
+}
+
+type Consumer interface {
+	Consume(t dep.T) error
+}`},
+		"conc": {`package conc
+
+type Both struct{}`},
+	})
+
+	req := &Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "b *Both",
+	}
+
+	req.IfaceName = "Producer"
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (b *Both) Produce() dep.T {") || strings.Contains(src, "Consume") {
+		t.Errorf("Producer run produced wrong methods:\n%s", src)
+	}
+
+	req.IfaceName = "Consumer"
+	resp, err = Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = string(resp.File)
+	if !strings.Contains(src, "func (b *Both) Consume(t dep.T) error {") || strings.Contains(src, "Produce()") {
+		t.Errorf("Consumer run produced wrong methods:\n%s", src)
+	}
+}
+
+// TestEditsAndVersion checks that the response carries the caller's
+// document version and position-anchored edits that reproduce File
+// when applied to the original contents.
+func TestEditsAndVersion(t *testing.T) {
+	orig := `package conc
+
+type T struct{}
+`
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type I interface {
+	M() int
+}`},
+		"conc": {orig},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "I",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "t *T",
+		Version:      7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Version != 7 {
+		t.Errorf("Version = %d, want 7", resp.Version)
+	}
+	if len(resp.Edits) == 0 {
+		t.Fatal("no edits returned")
+	}
+	applied := orig
+	for i := len(resp.Edits) - 1; i >= 0; i-- {
+		e := resp.Edits[i]
+		applied = applied[:e.Start] + e.Text + applied[e.End:]
+	}
+	if applied != string(resp.File) {
+		t.Errorf("applying edits gives:\n%s\nwant:\n%s", applied, resp.File)
+	}
+}
+
+// TestFlatten checks that a struct embedding an interface gets
+// explicit forwarding methods, including for unnamed and variadic
+// parameters.
+func TestFlatten(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"io": {`package io
+
+type Reader interface {
+	Read(p []byte) (n int, err error)
+	ReadMany(...[]byte) error
+	Reset()
+}`},
+		"conc": {`package conc
+
+import "io"
+
+type S struct {
+	io.Reader
+}`},
+	})
+
+	resp, err := Flatten(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "io",
+		IfaceName:    "Reader",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "s *S",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"func (s *S) Read(p []byte) (n int, err error) {\n\treturn s.Reader.Read(p)\n}",
+		"func (s *S) ReadMany(a0 ...[]byte) error {\n\treturn s.Reader.ReadMany(a0...)\n}",
+		"func (s *S) Reset() {\n\ts.Reader.Reset()\n}",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing forwarding method %q:\n%s", want, src)
+		}
+	}
+}
+
+// TestPredeclaredAny checks that the predeclared any and comparable
+// identifiers stay unqualified and trigger no import.
+func TestPredeclaredAny(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Cache interface {
+	comparable
+	Load(key any) (any, bool)
+}`},
+		"conc": {`package conc
+
+type Mem struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Cache",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "m *Mem",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (m *Mem) Load(key any) (any, bool) {") {
+		t.Errorf("any not rendered as a bare identifier:\n%s", src)
+	}
+	if strings.Contains(src, "import") {
+		t.Errorf("no import should be added for predeclared identifiers:\n%s", src)
+	}
+	if strings.Contains(src, "comparable") {
+		t.Errorf("comparable should contribute nothing to the stubs:\n%s", src)
+	}
+}
+
+// TestEmbeddingCycle checks that a malformed, cyclic interface
+// embedding (as can exist mid-edit, with type errors tolerated)
+// terminates with an error instead of recursing forever.
+func TestEmbeddingCycle(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type A interface {
+	B
+	MA()
+}
+
+type B interface {
+	A
+	MB()
+}`},
+		"conc": {`package conc
+
+type C struct{}`},
+	})
+
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import("iface")
+	conf.Import("conc")
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := findInterface(prog, "iface", "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct, err := newConcreteType(prog, &Request{
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *C",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected embedding cycle error, got %v", err)
+	}
+}
+
+// TestExternalTestPackage checks that stubs can be generated into a
+// _test.go file whose concrete type lives in the external test package.
+func TestExternalTestPackage(t *testing.T) {
+	ctxt := buildutil.FakeContext(map[string]map[string]string{
+		"iface": {
+			"iface.go": `package iface
+
+type Store interface {
+	Get(key string) (string, bool)
+}`,
+		},
+		"conc": {
+			"conc.go": `package conc`,
+			"fake_test.go": `package conc_test
+
+type FakeStore struct{}`,
+		},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Store",
+		ConcretePath: "conc",
+		File:         "fake_test.go",
+		Target:       "s *FakeStore",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "package conc_test") {
+		t.Errorf("output is not the external test package file:\n%s", src)
+	}
+	if !strings.Contains(src, "func (s *FakeStore) Get(key string) (string, bool) {") {
+		t.Errorf("missing Get stub:\n%s", src)
+	}
+}
+
+// TestConventionalPackageName checks that when a package's declared
+// name differs from its directory name, the generated selector and the
+// added import both use the declared name, the way goimports renders
+// such imports.
+func TestConventionalPackageName(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"gopkg.in/yaml.v2": {`package yaml
+
+type Node struct{}`},
+		"iface": {`package iface
+
+import "gopkg.in/yaml.v2"
+
+type Marshaler interface {
+	MarshalNode() *yaml.Node
+}`},
+		"conc": {`package conc
+
+type Doc struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Marshaler",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "d *Doc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (d *Doc) MarshalNode() *yaml.Node {") {
+		t.Errorf("selector does not use the declared package name:\n%s", src)
+	}
+	if !strings.Contains(src, "\"gopkg.in/yaml.v2\"") || strings.Contains(src, "yaml \"gopkg.in/yaml.v2\"") {
+		t.Errorf("import should be added without a redundant local name:\n%s", src)
+	}
+}
+
+func TestCustomTemplate(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Doer interface {
+	Do(what string) (int, error)
+}`},
+		"conc": {`package conc
+
+type Robot struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Doer",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "r *Robot",
+		Template: `
+func ({{.Recv}}) {{.Name}}{{.Signature}} {
+	log.Println("{{.Name}} called by", {{.RecvName}})
+	panic("not implemented")
+}
+`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, `log.Println("Do called by", r)`) {
+		t.Errorf("custom template not rendered:\n%s", src)
+	}
+
+	_, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Doer",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "r *Robot",
+		Template:     "{{.Name", // malformed
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid method template") {
+		t.Errorf("expected template parse error, got %v", err)
+	}
+}
+
+// TestMultiByteContent checks that a concrete file containing
+// multi-byte UTF-8 characters round-trips through stub generation
+// unchanged.  Implement replaces the whole file, so any editor-side
+// range arithmetic (e.g. UTF-16 column conversion for LSP) happens on
+// top of this output; the byte content itself must not be disturbed.
+func TestMultiByteContent(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Greeter interface {
+	Greet() string
+}`},
+		"conc": {`package conc
+
+// 世界 says hello. 👋
+const greeting = "こんにちは"
+
+type Hello struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Greeter",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "h *Hello",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"// 世界 says hello. 👋",
+		`const greeting = "こんにちは"`,
+		"func (h *Hello) Greet() string {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing %q:\n%s", want, src)
+		}
+	}
+}
+
+// TestAnonymousStructResult checks that qualification descends into
+// anonymous struct and interface types appearing in signatures.
+func TestAnonymousStructResult(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"net": {`package net
+
+type IP []byte`},
+		"iface": {`package iface
+
+import "net"
+
+type Server interface {
+	Config() struct {
+		Addr net.IP
+		Port int
+	}
+	Dialer() interface {
+		Dial(ip net.IP) error
+	}
+}`},
+		"conc": {`package conc
+
+type Srv struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Server",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "s *Srv",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "Addr net.IP") {
+		t.Errorf("anonymous struct field not qualified:\n%s", src)
+	}
+	if !strings.Contains(src, "Dial(ip net.IP) error") {
+		t.Errorf("anonymous interface method not qualified:\n%s", src)
+	}
+	if !strings.Contains(src, `"net"`) {
+		t.Errorf("net import not added:\n%s", src)
+	}
+}
+
+func TestIsCgoArtifact(t *testing.T) {
+	for name, want := range map[string]bool{
+		"_cgo_gotypes.go": true,
+		"_cgo_import.go":  true,
+		"handle.go":       false,
+	} {
+		if got := isCgoArtifact(name); got != want {
+			t.Errorf("isCgoArtifact(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+// TestCgoFallback checks that when the interface's source declaration
+// is unavailable (as when its declaring file is a cgo artifact), stubs
+// are still generated from type information alone.
+func TestCgoFallback(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"cgoiface": {`package cgoiface
+
+type Handle interface {
+	Bytes(n int) []byte
+}`},
+		"conc": {`package conc
+
+type Buf struct{}`},
+	})
+
+	conf := loader.Config{Build: ctxt}
+	conf.Import("cgoiface")
+	conf.Import("conc")
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := prog.Package("cgoiface")
+	iface := info.Pkg.Scope().Lookup("Handle").Type().Underlying().(*types.Interface)
+
+	ct, err := newConcreteType(prog, &Request{
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "b *Buf",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate the declaring file being a cgo artifact: no decl, only
+	// type information.
+	it := &ifaceType{name: "Handle", pkg: info, typ: iface}
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ct.sourceWithStubs(methods, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (b *Buf) Bytes(n int) []byte {") {
+		t.Errorf("missing Bytes stub in output:\n%s", resp.File)
+	}
+}
+
+func TestMainPackage(t *testing.T) {
+	// A main package cannot be imported by other packages, but the
+	// loader reaches it directly, so both a concrete type in main and
+	// an interface declared in main are fair game.
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"cmd/app": {`package main
+
+type Job struct{}
+
+type Notifier interface {
+	Notify() error
+}
+
+type Bell struct{}
+
+func main() {}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "cmd/app",
+		File:         "0.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (j *Job) Run() error {") {
+		t.Errorf("stub not generated in package main:\n%s", resp.File)
+	}
+
+	// The interface may itself live in the main package when the
+	// implementer is a neighbor there.
+	resp, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "cmd/app",
+		IfaceName:    "Notifier",
+		ConcretePath: "cmd/app",
+		File:         "0.go",
+		Target:       "b *Bell",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (b *Bell) Notify() error {") {
+		t.Errorf("stub not generated for a main-package interface:\n%s", resp.File)
+	}
+}
+
+// TestAliasedImport checks that when the concrete file imports a
+// package under a different name than the interface's file does, every
+// generated method uses the concrete file's name, and no duplicate
+// import is added.
+func TestAliasedImport(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"time": {`package time
+
+type Duration int64
+
+type Time struct{}`},
+		"iface": {`package iface
+
+import "time"
+
+type Waiter interface {
+	Wait(d time.Duration) error
+	Deadline() time.Time
+}`},
+		"conc": {`package conc
+
+import (
+	stdtime "time"
+)
+
+type Clock struct {
+	d stdtime.Duration
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Waiter",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Clock",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (c *Clock) Wait(d stdtime.Duration) error {") {
+		t.Errorf("Wait stub does not use the file's alias:\n%s", src)
+	}
+	if !strings.Contains(src, "func (c *Clock) Deadline() stdtime.Time {") {
+		t.Errorf("Deadline stub does not use the file's alias:\n%s", src)
+	}
+	if strings.Contains(src, "\n\t\"time\"") {
+		t.Errorf("redundant unaliased time import added:\n%s", src)
+	}
+}
+
+func TestSuggestConversions(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Thinger interface {
+	Thing(n int) error
+	Other(s string)
+}`},
+		"conc": {`package conc
+
+type Widget struct{}
+
+func doThing(w *Widget, n int) error { return nil }
+
+func unrelated(n int) error { return nil }`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:               ctxt,
+		IfacePath:          "iface",
+		IfaceName:          "Thinger",
+		ConcretePath:       "conc",
+		File:               "0.go",
+		Target:             "w *Widget",
+		SuggestConversions: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Conversions) != 1 {
+		t.Fatalf("got %d conversions %+v, want 1", len(resp.Conversions), resp.Conversions)
+	}
+	conv := resp.Conversions[0]
+	if conv.Func != "doThing" || conv.Method != "Thing" {
+		t.Errorf("conversion = %+v, want doThing -> Thing", conv)
+	}
+	if want := "Convert doThing to method Thing"; conv.Title != want {
+		t.Errorf("Title = %q, want %q", conv.Title, want)
+	}
+	// The stubs themselves are unaffected by the suggestion.
+	if !strings.Contains(string(resp.File), "func (w *Widget) Thing(n int) error {") {
+		t.Errorf("missing Thing stub in output:\n%s", resp.File)
+	}
+}
+
+// TestVendoredTypeQualification checks that a type the interface
+// references through a /vendor/ path is qualified and imported by its
+// canonical path in the generated stubs.
+func TestVendoredTypeQualification(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		// The vendor directory itself must exist for go/build's
+		// vendor search to descend into it.
+		"proj/vendor": {},
+		"proj/vendor/dep": {`package dep
+
+type Thing struct{}`},
+		"proj/iface": {`package iface
+
+import "dep"
+
+type Holder interface {
+	Hold(t dep.Thing) dep.Thing
+}`},
+		"proj/conc": {`package conc
+
+type Box struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "proj/iface",
+		IfaceName:    "Holder",
+		ConcretePath: "proj/conc",
+		File:         "0.go",
+		Target:       "b *Box",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (b *Box) Hold(t dep.Thing) dep.Thing {") {
+		t.Errorf("missing or misqualified Hold stub:\n%s", src)
+	}
+	if strings.Contains(src, "proj/vendor/dep") {
+		t.Errorf("import uses the vendored path:\n%s", src)
+	}
+	if n := strings.Count(src, `"dep"`); n != 1 {
+		t.Errorf("got %d imports of %q, want 1:\n%s", n, "dep", src)
+	}
+}
+
+func TestErrorPackage(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Store interface {
+	Get(key string) (string, error)
+	Reset()
+}`},
+		"conc": {`package conc
+
+type Mem struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Store",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "m *Mem",
+		ErrorPackage: "example.com/errutil",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, `return "", errutil.NotImplemented("Get")`) {
+		t.Errorf("Get body does not call the helper:\n%s", src)
+	}
+	if !strings.Contains(src, `"example.com/errutil"`) {
+		t.Errorf("helper package not imported:\n%s", src)
+	}
+	// A method without a trailing error gets a plain zero body.
+	if !strings.Contains(src, "func (m *Mem) Reset() {\n}") {
+		t.Errorf("Reset body is not empty:\n%s", src)
+	}
+}
+
+// TestNestedFuncTypeQualification checks that qualification recurses
+// into every level of a func type, not just the outermost signature.
+func TestNestedFuncTypeQualification(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"net/http": {`package http
+
+type Handler interface {
+	ServeHTTP()
+}`},
+		"iface": {`package iface
+
+import "net/http"
+
+type Wrapper interface {
+	Middleware() func(http.Handler) func(http.Handler) http.Handler
+}`},
+		"conc": {`package conc
+
+type Chain struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Wrapper",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Chain",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	want := "func (c *Chain) Middleware() func(http.Handler) func(http.Handler) http.Handler {"
+	if !strings.Contains(src, want) {
+		t.Errorf("missing or misqualified Middleware stub:\n%s", src)
+	}
+	if !strings.Contains(src, `"net/http"`) {
+		t.Errorf("net/http import not added:\n%s", src)
+	}
+	if n := strings.Count(src, "http.Handler"); n != 3 {
+		t.Errorf("got %d qualified Handler references, want 3:\n%s", n, src)
+	}
+}
+
+// TestLocalInterface checks that an interface declared inside a
+// function body can be implemented by a package-level type.
+func TestLocalInterface(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Recorder struct{}
+
+func use() {
+	type sink interface {
+		Record(event string) error
+	}
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "conc",
+		IfaceName:    "sink",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "r *Recorder",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (r *Recorder) Record(event string) error {") {
+		t.Errorf("missing Record stub in output:\n%s", resp.File)
+	}
+}
+
+// TestImportShadowsPackageIdent checks that an added import whose name
+// matches a package-level identifier is aliased rather than shadowing
+// it.
+func TestImportShadowsPackageIdent(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"time": {`package time
+
+type Time struct{}`},
+		"iface": {`package iface
+
+import "time"
+
+type Clock interface {
+	Now() time.Time
+}`},
+		"conc": {`package conc
+
+var time = 0
+
+type Wall struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Clock",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "w *Wall",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, `time2 "time"`) {
+		t.Errorf("time import not aliased:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Wall) Now() time2.Time {") {
+		t.Errorf("Now stub does not use the alias:\n%s", src)
+	}
+}
+
+// TestTargetFile checks new-file generation: the output is a complete
+// file whose imports do not depend on the original file's, and a _test
+// package clause imports the package under test.
+func TestTargetFile(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Sink interface {
+	Send(e events.Event) error
+}`},
+		"conc": {`package conc
+
+import "events"
+
+var _ events.Event
+
+type Queue struct{}`},
+	})
+
+	req := &Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Sink",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "q *Queue",
+		TargetFile:   "queue_sink.go",
+	}
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.HasPrefix(src, "package conc\n") {
+		t.Errorf("missing package clause:\n%s", src)
+	}
+	// The original file imports events, but the new file must import
+	// it for itself.
+	if !strings.Contains(src, `"events"`) {
+		t.Errorf("events import not added to the new file:\n%s", src)
+	}
+	if !strings.Contains(src, "func (q *Queue) Send(e events.Event) error {") {
+		t.Errorf("missing Send stub:\n%s", src)
+	}
+
+	req.TargetPackage = "conc_test"
+	resp, err = Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = string(resp.File)
+	if !strings.HasPrefix(src, "package conc_test\n") {
+		t.Errorf("missing _test package clause:\n%s", src)
+	}
+	if !strings.Contains(src, `"conc"`) {
+		t.Errorf("package under test not imported:\n%s", src)
+	}
+
+	req.TargetPackage = "other"
+	if _, err := Implement(req); err == nil || !strings.Contains(err.Error(), "does not match package") {
+		t.Errorf("mismatched clause: error = %v, want rejection", err)
+	}
+}
+
+// TestTypesOnlyInterfacePackage checks that stubs can be generated when
+// the interface's package has type information but no parsed files, as
+// happens when it was loaded for types only.
+func TestTypesOnlyInterfacePackage(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Waiter interface {
+	Wait(n int) (bool, error)
+}`},
+		"conc": {`package conc
+
+type Clock struct{}`},
+	})
+
+	conf := loader.Config{Build: ctxt}
+	conf.Import("iface")
+	conf.Import("conc")
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a types-only load of the interface's package.
+	prog.Package("iface").Files = nil
+
+	it, err := findInterface(prog, "iface", "Waiter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if it.decl != nil || it.typ == nil {
+		t.Fatalf("findInterface = %+v, want a types-only interface", it)
+	}
+
+	ct, err := newConcreteType(prog, &Request{
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Clock",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ct.sourceWithStubs(methods, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (c *Clock) Wait(n int) (bool, error) {") {
+		t.Errorf("missing Wait stub in output:\n%s", resp.File)
+	}
+}
+
+// TestExplicitOverEmbedded checks that methods satisfied only through
+// an embedded interface-typed field can be stubbed explicitly.
+func TestExplicitOverEmbedded(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Partial interface {
+	A() error
+}
+
+type Full interface {
+	Partial
+	B() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Wrapper struct {
+	iface.Partial
+}`},
+	})
+
+	req := &Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Full",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "w *Wrapper",
+	}
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if strings.Contains(src, "func (w *Wrapper) A() error {") {
+		t.Errorf("A is promoted and should be skipped by default:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Wrapper) B() error {") {
+		t.Errorf("missing B stub:\n%s", src)
+	}
+
+	req.ExplicitOverEmbedded = true
+	resp, err = Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = string(resp.File)
+	if !strings.Contains(src, "func (w *Wrapper) A() error {") {
+		t.Errorf("A not generated despite ExplicitOverEmbedded:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Wrapper) B() error {") {
+		t.Errorf("missing B stub:\n%s", src)
+	}
+}
+
+// TestConcretePackageIdentifiers checks that identifiers resolving to
+// the concrete package are rendered bare, whether the interface's file
+// referenced them through a qualifier or a dot import.
+func TestConcretePackageIdentifiers(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Thing struct{}
+
+type Box struct{}
+
+type Crate struct{}`},
+		"iface": {`package iface
+
+import "conc"
+
+type Filler interface {
+	Fill(t conc.Thing) conc.Thing
+}`},
+		"dotiface": {`package dotiface
+
+import . "conc"
+
+type Packer interface {
+	Pack(t Thing) Thing
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Filler",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "b *Box",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (b *Box) Fill(t Thing) Thing {") {
+		t.Errorf("qualified reference not rendered bare:\n%s", src)
+	}
+	if strings.Contains(src, "conc.Thing") || strings.Contains(src, `import "conc"`) {
+		t.Errorf("concrete package qualified or imported into itself:\n%s", src)
+	}
+
+	resp, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "dotiface",
+		IfaceName:    "Packer",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Crate",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = string(resp.File)
+	if !strings.Contains(src, "func (c *Crate) Pack(t Thing) Thing {") {
+		t.Errorf("dot-imported reference not rendered bare:\n%s", src)
+	}
+}
+
+// TestGranularInsertNearComments stresses the granular offsets when
+// declarations are immediately followed by comments: the spliced result
+// must still parse.
+func TestGranularInsertNearComments(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Sink interface {
+	Send(e events.Event) error
+}`},
+		"conc": {`package conc // conc holds concrete types.
+
+// Queue is a FIFO. It has a trailing comment below.
+type Queue struct{} // see Send
+`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Sink",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "q *Queue",
+		Granular:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, `"events"`) {
+		t.Errorf("events import not spliced:\n%s", src)
+	}
+	if !strings.Contains(src, "func (q *Queue) Send(e events.Event) error {") {
+		t.Errorf("missing Send stub:\n%s", src)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "0.go", resp.File, 0); err != nil {
+		t.Errorf("spliced file does not parse: %v\n%s", err, src)
+	}
+}
+
+// TestArrayLengthQualification checks that the length expression of an
+// array type is qualified and its import collected, not just the
+// element type.
+func TestArrayLengthQualification(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"crypto/sha256": {`package sha256
+
+const Size = 32`},
+		"iface": {`package iface
+
+import "crypto/sha256"
+
+type Hasher interface {
+	Sum() [sha256.Size]byte
+}`},
+		"conc": {`package conc
+
+type Digest struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Hasher",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "d *Digest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (d *Digest) Sum() [sha256.Size]byte {") {
+		t.Errorf("array length not qualified:\n%s", src)
+	}
+	if !strings.Contains(src, `"crypto/sha256"`) {
+		t.Errorf("crypto/sha256 import not added:\n%s", src)
+	}
+}
+
+// TestGenericInstantiationInSignature checks that generic
+// instantiations in method signatures are qualified at both the base
+// type and the type arguments.
+func TestGenericInstantiationInSignature(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"res": {`package res
+
+type Result[T any] struct{}
+
+type Pair[K comparable, V any] struct{}`},
+		"events": {`package events
+
+type Key struct{}`},
+		"iface": {`package iface
+
+import (
+	"events"
+	"res"
+)
+
+type Lister interface {
+	List() []res.Result[events.Key]
+	Index() res.Pair[string, events.Key]
+}`},
+		"conc": {`package conc
+
+type Store struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Lister",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "s *Store",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (s *Store) List() []res.Result[events.Key] {") {
+		t.Errorf("instantiation not qualified:\n%s", src)
+	}
+	if !strings.Contains(src, "func (s *Store) Index() res.Pair[string, events.Key] {") {
+		t.Errorf("multi-argument instantiation not qualified:\n%s", src)
+	}
+	for _, imp := range []string{`"res"`, `"events"`} {
+		if !strings.Contains(src, imp) {
+			t.Errorf("missing import %s:\n%s", imp, src)
+		}
+	}
+}
+
+func TestNameContext(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"context": {`package context
+
+type Context interface {
+	Err() error
+}`},
+		"iface": {`package iface
+
+import "context"
+
+type Runner interface {
+	Run(context.Context, int) error
+	Stop(c context.Context) error
+}`},
+		"conc": {`package conc
+
+type Job struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+		NameContext:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (j *Job) Run(ctx context.Context, a1 int) error {") {
+		t.Errorf("blank context parameter not named ctx:\n%s", src)
+	}
+	// A name chosen by the interface author wins over the convention.
+	if !strings.Contains(src, "func (j *Job) Stop(c context.Context) error {") {
+		t.Errorf("named context parameter was renamed:\n%s", src)
+	}
+}
+
+// TestInlineInterfaceParam checks that qualification descends into an
+// anonymous interface literal used as a parameter type, since its
+// methods can reference types from the interface's package.
+func TestInlineInterfaceParam(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Walker interface {
+	Accept(v interface {
+		Visit(e events.Event) error
+	})
+}`},
+		"conc": {`package conc
+
+type Tree struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Walker",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "t *Tree",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "Visit(e events.Event) error") {
+		t.Errorf("inline interface method not qualified:\n%s", src)
+	}
+	if !strings.Contains(src, `"events"`) {
+		t.Errorf("events not imported:\n%s", src)
+	}
+}
+
+func TestIfaceTypeArgs(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Handler[T any] interface {
+	Handle(v T) error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Event struct{}
+
+type Processor struct{}
+
+var _ iface.Handler[Event] = (*Processor)(nil)`},
+	})
+
+	assertions, err := FindAssertions(ctxt, "conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assertions) != 1 {
+		t.Fatalf("got %d assertions %+v, want 1", len(assertions), assertions)
+	}
+	a := assertions[0]
+	if len(a.IfaceTypeArgs) != 1 || a.IfaceTypeArgs[0] != "Event" {
+		t.Fatalf("IfaceTypeArgs = %v, want [Event]", a.IfaceTypeArgs)
+	}
+
+	resp, err := Implement(&Request{
+		Ctxt:          ctxt,
+		IfacePath:     a.IfacePath,
+		IfaceName:     a.IfaceName,
+		IfaceTypeArgs: a.IfaceTypeArgs,
+		ConcretePath:  "conc",
+		File:          "0.go",
+		Target:        a.Target,
+		AllowErrors:   true, // the assertion itself does not yet compile
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (p *Processor) Handle(v Event) error {") {
+		t.Errorf("type parameter not substituted:\n%s", src)
+	}
+	if strings.Contains(src, "[T") || strings.Contains(src, "(T)") {
+		t.Errorf("type parameter leaked into output:\n%s", src)
+	}
+	// Instantiation works from type information by design; that is no
+	// reason to warn that the interface's source is unavailable.
+	for _, n := range resp.Notes {
+		if strings.Contains(n, "unavailable") {
+			t.Errorf("unexpected note: %q", n)
+		}
+	}
+}
+
+func TestMatchDocStyle(t *testing.T) {
+	const iface = `package iface
+
+type Runner interface {
+	Run() error
+	Stop() error
+}`
+	documented := `package conc
+
+type Job struct{}
+
+// Name returns the job's name.
+func (j *Job) Name() string { return "" }`
+	undocumented := `package conc
+
+type Job struct{}
+
+func (j *Job) Name() string { return "" }`
+
+	for _, tt := range []struct {
+		name string
+		conc string
+		want bool
+	}{
+		{"documented", documented, true},
+		{"undocumented", undocumented, false},
+	} {
+		ctxt := fakeContext(map[string][]string{
+			"iface": {iface},
+			"conc":  {tt.conc},
+		})
+		resp, err := Implement(&Request{
+			Ctxt:          ctxt,
+			IfacePath:     "iface",
+			IfaceName:     "Runner",
+			ConcretePath:  "conc",
+			File:          "0.go",
+			Target:        "j *Job",
+			MatchDocStyle: true,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		src := string(resp.File)
+		if got := strings.Contains(src, "// Run ...\nfunc (j *Job) Run() error {"); got != tt.want {
+			t.Errorf("%s: stub documented = %v, want %v:\n%s", tt.name, got, tt.want, src)
+		}
+	}
+}
+
+// Generic alias resolution itself is exercised in the go1.24-gated
+// test file; an alias of an instantiated generic type can be rejected
+// on any version.
+func TestInstantiatedAliasConcrete(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Cache[T any] struct{}
+
+type IntCache = Cache[int]`},
+	})
+
+	_, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *IntCache",
+	})
+	if err == nil || !strings.Contains(err.Error(), "aliases an instantiated generic type") {
+		t.Fatalf("err = %v, want instantiated-alias rejection", err)
+	}
+}
+
+// TestNoImportBlockPlacement adds imports to a concrete file that
+// imported nothing: the new block must land right after the package
+// clause, leaving the license header above the clause and the doc
+// comment on the existing declaration where they were.
+func TestNoImportBlockPlacement(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"codes": {`package codes
+
+type Code int`},
+		"iface": {`package iface
+
+import (
+	"codes"
+	"events"
+)
+
+type Sink interface {
+	Send(e events.Event) (codes.Code, error)
+}`},
+		"conc": {`// Copyright 2018 The Authors.
+// All rights reserved.
+
+// Package conc does concrete things.
+package conc
+
+// Job is a unit of work.
+type Job struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Sink",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.HasPrefix(src, "// Copyright 2018 The Authors.") {
+		t.Errorf("license header displaced:\n%s", src)
+	}
+	if !strings.Contains(src, "package conc\n\nimport (\n\t\"codes\"\n\t\"events\"\n)") {
+		t.Errorf("imports not in a single block after the package clause:\n%s", src)
+	}
+	if !strings.Contains(src, "// Job is a unit of work.\ntype Job struct{}") {
+		t.Errorf("doc comment separated from its declaration:\n%s", src)
+	}
+}
+
+// TestIfaceTypeArgsNested instantiates a generic interface whose
+// methods use the type parameter in nested positions, with a
+// cross-package argument: the substituted types must be qualified and
+// their package imported.
+func TestIfaceTypeArgsNested(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type User struct{}`},
+		"iface": {`package iface
+
+type Repo[T any] interface {
+	Find(id string) (*T, error)
+	List() ([]T, error)
+	ByName() (map[string]T, error)
+}`},
+		"conc": {`package conc
+
+import "events"
+
+var _ = events.User{}
+
+type Store struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:          ctxt,
+		IfacePath:     "iface",
+		IfaceName:     "Repo",
+		IfaceTypeArgs: []string{"events.User"},
+		ConcretePath:  "conc",
+		File:          "0.go",
+		Target:        "s *Store",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"Find(id string) (*events.User, error)",
+		"List() ([]events.User, error)",
+		"ByName() (map[string]events.User, error)",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q:\n%s", want, src)
+		}
+	}
+	if !strings.Contains(src, `"events"`) {
+		t.Errorf("events not imported:\n%s", src)
+	}
+}
+
+func TestSentinelError(t *testing.T) {
+	const iface = `package iface
+
+type Store interface {
+	Get(key string) (string, error)
+	Reset()
+}`
+	// The fake file system has no standard library, so the fixture
+	// carries its own errors package.
+	const errorsPkg = `package errors
+
+type errorString struct{ s string }
+
+func (e *errorString) Error() string { return e.s }
+
+func New(text string) error { return &errorString{text} }`
+	t.Run("present", func(t *testing.T) {
+		ctxt := fakeContext(map[string][]string{
+			"iface":  {iface},
+			"errors": {errorsPkg},
+			"conc": {`package conc
+
+import "errors"
+
+var errNotImplemented = errors.New("not implemented")
+
+type Mem struct{}`},
+		})
+		resp, err := Implement(&Request{
+			Ctxt:          ctxt,
+			IfacePath:     "iface",
+			IfaceName:     "Store",
+			ConcretePath:  "conc",
+			File:          "0.go",
+			Target:        "m *Mem",
+			SentinelError: "errNotImplemented",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := string(resp.File)
+		if !strings.Contains(src, `return "", errNotImplemented`) {
+			t.Errorf("Get does not return the sentinel:\n%s", src)
+		}
+		if strings.Count(src, "var errNotImplemented") != 1 {
+			t.Errorf("sentinel redeclared:\n%s", src)
+		}
+		if len(resp.Notes) != 0 {
+			t.Errorf("unexpected notes %q", resp.Notes)
+		}
+	})
+	t.Run("absent", func(t *testing.T) {
+		ctxt := fakeContext(map[string][]string{
+			"iface": {iface},
+			"conc": {`package conc
+
+type Mem struct{}`},
+		})
+		resp, err := Implement(&Request{
+			Ctxt:          ctxt,
+			IfacePath:     "iface",
+			IfaceName:     "Store",
+			ConcretePath:  "conc",
+			File:          "0.go",
+			Target:        "m *Mem",
+			SentinelError: "errNotImplemented",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := string(resp.File)
+		if !strings.Contains(src, `var errNotImplemented = errors.New("not implemented")`) {
+			t.Errorf("sentinel not declared:\n%s", src)
+		}
+		if !strings.Contains(src, `"errors"`) {
+			t.Errorf("errors not imported:\n%s", src)
+		}
+		if len(resp.Notes) != 1 || !strings.Contains(resp.Notes[0], "has been declared") {
+			t.Errorf("notes = %q, want a declaration note", resp.Notes)
+		}
+	})
+	t.Run("wrong type", func(t *testing.T) {
+		ctxt := fakeContext(map[string][]string{
+			"iface": {iface},
+			"conc": {`package conc
+
+var errNotImplemented = 42
+
+type Mem struct{}`},
+		})
+		_, err := Implement(&Request{
+			Ctxt:          ctxt,
+			IfacePath:     "iface",
+			IfaceName:     "Store",
+			ConcretePath:  "conc",
+			File:          "0.go",
+			Target:        "m *Mem",
+			SentinelError: "errNotImplemented",
+		})
+		if err == nil || !strings.Contains(err.Error(), "not a package-level error variable") {
+			t.Fatalf("err = %v, want type rejection", err)
+		}
+	})
+}
+
+func TestBareGenericInterface(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Handler[T any] interface {
+	Handle(v T) error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Processor struct{}
+
+var _ iface.Handler = (*Processor)(nil)`},
+	})
+
+	_, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Handler",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "p *Processor",
+		AllowErrors:  true,
+	})
+	if err == nil || err.Error() != "interface Handler requires type arguments; e.g. Handler[SomeType]" {
+		t.Fatalf("err = %v, want guidance about missing type arguments", err)
+	}
+}
+
+func TestNamedResults(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Store interface {
+	Get(key string) (string, int, error)
+	Count(result int) (int, error)
+	Find(id string) (found string)
+}`},
+		"conc": {`package conc
+
+type Mem struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Store",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "m *Mem",
+		NamedResults: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "Get(key string) (result string, result2 int, err error)") {
+		t.Errorf("results not named:\n%s", src)
+	}
+	// "result" is taken by the parameter, so the result moves on.
+	if !strings.Contains(src, "Count(result int) (result2 int, err error)") {
+		t.Errorf("parameter collision not avoided:\n%s", src)
+	}
+	// A result the interface named keeps its name.
+	if !strings.Contains(src, "Find(id string) (found string)") {
+		t.Errorf("interface-chosen result name not preserved:\n%s", src)
+	}
+}
+
+func TestInsertLine(t *testing.T) {
+	conc := `package conc
+
+type Job struct{}
+
+type Other struct{}
+`
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {conc},
+	})
+
+	run := func(line int) string {
+		resp, err := Implement(&Request{
+			Ctxt:         ctxt,
+			IfacePath:    "iface",
+			IfaceName:    "Runner",
+			ConcretePath: "conc",
+			File:         "0.go",
+			Target:       "j *Job",
+			Granular:     true,
+			InsertLine:   line,
+		})
+		if err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		return string(resp.File)
+	}
+
+	// Line 5 is the boundary before the Other declaration.
+	src := run(5)
+	run1 := strings.Index(src, "func (j *Job) Run() error {")
+	other := strings.Index(src, "type Other struct{}")
+	if run1 < 0 || other < 0 || run1 > other {
+		t.Errorf("methods not inserted before Other:\n%s", src)
+	}
+	// With no line requested the methods keep their usual place at
+	// the end of the file.
+	src = run(0)
+	if !strings.HasSuffix(strings.TrimSpace(src), "}") || strings.Index(src, "type Other") > strings.Index(src, "func (j *Job) Run() error {") {
+		t.Errorf("default placement not at end of file:\n%s", src)
+	}
+}
+
+func TestActionTitle(t *testing.T) {
+	resp := &Response{}
+	if got, want := resp.ActionTitle("io.Writer"), "Implement io.Writer"; got != want {
+		t.Errorf("ActionTitle = %q, want %q", got, want)
+	}
+	resp.Imports = []*AddedImport{{Path: "bytes"}}
+	if got, want := resp.ActionTitle("io.Writer"), `Implement io.Writer (adds import "bytes")`; got != want {
+		t.Errorf("ActionTitle = %q, want %q", got, want)
+	}
+	resp.Imports = append(resp.Imports, &AddedImport{Name: "yaml", Path: "gopkg.in/yaml.v2"})
+	if got, want := resp.ActionTitle("io.Writer"), `Implement io.Writer (adds imports "bytes", yaml "gopkg.in/yaml.v2")`; got != want {
+		t.Errorf("ActionTitle = %q, want %q", got, want)
+	}
+}
+
+// TestIfaceTypeArgsMapKeyValue instantiates a generic interface whose
+// method returns map[K]V with cross-package arguments for both
+// parameters; both packages must be imported.
+func TestIfaceTypeArgsMapKeyValue(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"keys": {`package keys
+
+type ID string`},
+		"vals": {`package vals
+
+type Record struct{}`},
+		"iface": {`package iface
+
+type Table[K comparable, V any] interface {
+	Index() map[K]V
+}`},
+		"conc": {`package conc
+
+import (
+	"keys"
+	"vals"
+)
+
+var (
+	_ = keys.ID("")
+	_ = vals.Record{}
+)
+
+type Store struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:          ctxt,
+		IfacePath:     "iface",
+		IfaceName:     "Table",
+		IfaceTypeArgs: []string{"keys.ID", "vals.Record"},
+		ConcretePath:  "conc",
+		File:          "0.go",
+		Target:        "s *Store",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "Index() map[keys.ID]vals.Record") {
+		t.Errorf("map type not substituted and qualified:\n%s", src)
+	}
+	for _, imp := range []string{`"keys"`, `"vals"`} {
+		if !strings.Contains(src, imp) {
+			t.Errorf("missing import %s:\n%s", imp, src)
+		}
+	}
+}
+
+func TestCanonicalPaths(t *testing.T) {
+	// The interface's package stands in for a dependency replaced with
+	// a local copy: it loads under the on-disk path "work/hooks" but
+	// must be imported by its canonical module path.
+	ctxt := fakeContext(map[string][]string{
+		"work/hooks": {`package hooks
+
+type Event struct{}
+
+type Listener interface {
+	Notify(e Event) error
+}`},
+		"conc": {`package conc
+
+type Recorder struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "work/hooks",
+		IfaceName:    "Listener",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "r *Recorder",
+		CanonicalPaths: map[string]string{
+			"work/hooks": "example.com/hooks",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, `"example.com/hooks"`) {
+		t.Errorf("canonical path not imported:\n%s", src)
+	}
+	if strings.Contains(src, `"work/hooks"`) {
+		t.Errorf("on-disk replacement path imported:\n%s", src)
+	}
+	if !strings.Contains(src, "func (r *Recorder) Notify(e hooks.Event) error {") {
+		t.Errorf("method not qualified through the canonical import:\n%s", src)
+	}
+}
+
+func TestBrokenSiblingFile(t *testing.T) {
+	// An error in a file unrelated to the target must not block
+	// generation; packages are often mid-edit.
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Job struct{}`, `package conc
+
+func broken() {
+	undefinedIdent()
+}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(resp.File), "func (j *Job) Run() error {") {
+		t.Errorf("stub not generated:\n%s", resp.File)
+	}
+
+	// A target that genuinely does not resolve still fails precisely.
+	_, err = Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "m *Missing",
+	})
+	if err == nil || !strings.Contains(err.Error(), "no type named Missing") {
+		t.Errorf("err = %v, want missing-type rejection", err)
+	}
+}
+
+func TestRegionMarkers(t *testing.T) {
+	files := map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+	Stop()
+}`},
+		"conc": {`package conc
+
+type Job struct{}`},
+	}
+	req := &Request{
+		IfacePath:     "iface",
+		IfaceName:     "Runner",
+		ConcretePath:  "conc",
+		File:          "0.go",
+		Target:        "j *Job",
+		RegionMarkers: true,
+	}
+
+	req.Ctxt = fakeContext(files)
+	resp, err := Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "// impl:begin Runner") || !strings.Contains(src, "// impl:end Runner") {
+		t.Fatalf("markers not emitted:\n%s", src)
+	}
+	begin := strings.Index(src, "// impl:begin Runner")
+	end := strings.Index(src, "// impl:end Runner")
+	if run := strings.Index(src, "func (j *Job) Run() error {"); run < begin || run > end {
+		t.Errorf("stub not inside the marked region:\n%s", src)
+	}
+
+	// A re-run against the generated file replaces the region instead
+	// of appending a second copy.
+	files["conc"] = []string{src}
+	req.Ctxt = fakeContext(files)
+	resp, err = Implement(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = string(resp.File)
+	if n := strings.Count(src, "// impl:begin Runner"); n != 1 {
+		t.Errorf("%d begin markers after re-run, want 1:\n%s", n, src)
+	}
+	if n := strings.Count(src, "func (j *Job) Run() error {"); n != 1 {
+		t.Errorf("%d Run stubs after re-run, want 1:\n%s", n, src)
+	}
+	if n := strings.Count(src, "func (j *Job) Stop() {"); n != 1 {
+		t.Errorf("%d Stop stubs after re-run, want 1:\n%s", n, src)
+	}
+}
+
+func TestAliasOfErrorResult(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Err = error
+
+type MyErr error
+
+type Service interface {
+	DoA() Err
+	DoB() MyErr
+}`},
+		"conc": {`package conc
+
+type Impl struct{}`},
+	})
+
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Service",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "i *Impl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	// The alias dissolves into the builtin, which cannot be imported;
+	// the defined type is the interface package's own and is qualified.
+	if !strings.Contains(src, "func (i *Impl) DoA() error {") {
+		t.Errorf("alias of error not rendered as the builtin:\n%s", src)
+	}
+	if !strings.Contains(src, "func (i *Impl) DoB() iface.MyErr {") {
+		t.Errorf("defined error type not qualified:\n%s", src)
+	}
+	if strings.Contains(src, "iface.Err") {
+		t.Errorf("alias qualified instead of resolved:\n%s", src)
+	}
+}
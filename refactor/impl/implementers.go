@@ -0,0 +1,90 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"fmt"
+	"go/build"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// Implementers returns, sorted, the named types among the known
+// packages whose method sets satisfy the given interface, each as a
+// qualified "pkg.Type" name, prefixed with "*" when only the pointer
+// type satisfies it.  It powers go-to-implementations style queries
+// and lets users audit which types a change to an interface would
+// affect.
+//
+// scope, if non-empty, restricts the scan to import paths with that
+// prefix, so large GOPATHs can be capped to the workspace.  The scan
+// honors ctx and returns ctx.Err() once it is cancelled.
+func Implementers(ctx context.Context, ctxt *build.Context, ipath, iname, scope string) ([]string, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	paths, err := KnownPackages(ctx, ctxt, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Broken packages elsewhere in the workspace should not block the
+	// query; load permissively.
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(ipath)
+	for _, path := range paths {
+		if scope == "" || path == scope || strings.HasPrefix(path, scope+"/") {
+			conf.Import(path)
+		}
+	}
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	iobj, ok := prog.Package(ipath).Pkg.Scope().Lookup(iname).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("no interface named %s in package %q", iname, ipath)
+	}
+	iface, ok := iobj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an interface", iname)
+	}
+
+	var found []string
+	for _, info := range prog.Imported {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		scope := info.Pkg.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || obj == iobj {
+				continue
+			}
+			named, ok := obj.Type().(*types.Named)
+			if !ok || types.IsInterface(named) {
+				continue
+			}
+			qualified := info.Pkg.Path() + "." + name
+			switch {
+			case types.Implements(named, iface):
+				found = append(found, qualified)
+			case types.Implements(types.NewPointer(named), iface):
+				found = append(found, "*"+qualified)
+			}
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
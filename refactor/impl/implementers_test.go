@@ -0,0 +1,52 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestImplementers(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Waiter interface {
+	Wait() error
+}`},
+		"a": {`package a
+
+type Good struct{}
+
+func (g *Good) Wait() error { return nil }
+
+type Bad struct{}
+
+func (b *Bad) Wait() {} // wrong signature`},
+		"b": {`package b
+
+type Also struct{}
+
+func (a Also) Wait() error { return nil }`},
+	})
+
+	got, err := Implementers(context.Background(), ctxt, "iface", "Waiter", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*a.Good", "b.Also"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Implementers = %v, want %v", got, want)
+	}
+
+	got, err = Implementers(context.Background(), ctxt, "iface", "Waiter", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b.Also"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Implementers scoped to b = %v, want %v", got, want)
+	}
+}
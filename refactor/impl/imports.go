@@ -0,0 +1,89 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/types"
+	"sort"
+)
+
+// An AddedImport describes an import that must be added to a file so
+// that generated method stubs compile there.
+type AddedImport struct {
+	Name string // local name for the import; "" for a default import
+	Path string
+}
+
+// RequiredImports returns, sorted by path, the imports needed to render
+// the signatures of the given methods in toPkg.  Methods' signatures
+// reference types from the packages that declared them (and their
+// dependencies); every such package other than toPkg must be imported.
+//
+// Unlike the collection performed during Implement, which also consults
+// the destination file's existing imports to reuse their local names,
+// RequiredImports is a pure function of the type information, which
+// makes it reusable where no destination file exists yet.
+func RequiredImports(methods []*types.Func, toPkg *types.Package) []*AddedImport {
+	pkgs := make(map[*types.Package]bool)
+	seen := make(map[types.Type]bool)
+	for _, m := range methods {
+		collectPackages(m.Type(), toPkg, pkgs, seen)
+	}
+	var added []*AddedImport
+	for pkg := range pkgs {
+		added = append(added, &AddedImport{Path: pkg.Path()})
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Path < added[j].Path })
+	return added
+}
+
+// collectPackages records in pkgs every package other than toPkg whose
+// types appear in t.
+func collectPackages(t types.Type, toPkg *types.Package, pkgs map[*types.Package]bool, seen map[types.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	switch t := t.(type) {
+	case *types.Named:
+		if pkg := t.Obj().Pkg(); pkg != nil && pkg != toPkg {
+			pkgs[pkg] = true
+		}
+		// An instantiation such as box.List[item.Item] spells its type
+		// arguments too, so their packages are needed alongside the
+		// named type's own.
+		if targs := t.TypeArgs(); targs != nil {
+			for i := 0; i < targs.Len(); i++ {
+				collectPackages(targs.At(i), toPkg, pkgs, seen)
+			}
+		}
+	case *types.Pointer:
+		collectPackages(t.Elem(), toPkg, pkgs, seen)
+	case *types.Slice:
+		collectPackages(t.Elem(), toPkg, pkgs, seen)
+	case *types.Array:
+		collectPackages(t.Elem(), toPkg, pkgs, seen)
+	case *types.Map:
+		collectPackages(t.Key(), toPkg, pkgs, seen)
+		collectPackages(t.Elem(), toPkg, pkgs, seen)
+	case *types.Chan:
+		collectPackages(t.Elem(), toPkg, pkgs, seen)
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			collectPackages(t.Field(i).Type(), toPkg, pkgs, seen)
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			collectPackages(t.Method(i).Type(), toPkg, pkgs, seen)
+		}
+	case *types.Signature:
+		collectPackages(t.Params(), toPkg, pkgs, seen)
+		collectPackages(t.Results(), toPkg, pkgs, seen)
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			collectPackages(t.At(i).Type(), toPkg, pkgs, seen)
+		}
+	}
+}
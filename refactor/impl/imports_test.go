@@ -0,0 +1,103 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/loader"
+)
+
+func TestRequiredImports(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"dep": {`package dep
+
+type T struct{}`},
+		"box": {`package box
+
+type List[T any] struct{}`},
+		"item": {`package item
+
+type Item struct{}`},
+		"api": {`package api
+
+import (
+	"box"
+	"dep"
+	"item"
+)
+
+type Local int
+
+type I interface {
+	Cross(t dep.T) *dep.T
+	Same(l Local) Local
+	Builtin(n int) (string, error)
+	Nested() map[string][]chan dep.T
+}
+
+type G interface {
+	Boxed() box.List[item.Item]
+}`},
+		"conc": {`package conc
+
+type C struct{}`},
+	})
+
+	conf := loader.Config{Build: ctxt}
+	conf.Import("api")
+	conf.Import("conc")
+	prog, err := conf.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := prog.Package("api").Pkg
+	concPkg := prog.Package("conc").Pkg
+	iface := api.Scope().Lookup("I").Type().Underlying().(*types.Interface)
+	var methods []*types.Func
+	var builtin *types.Func
+	for i := 0; i < iface.NumMethods(); i++ {
+		m := iface.Method(i)
+		methods = append(methods, m)
+		if m.Name() == "Builtin" {
+			builtin = m
+		}
+	}
+
+	// Rendering in a third package needs both api (for Local) and dep.
+	got := RequiredImports(methods, concPkg)
+	if len(got) != 2 || got[0].Path != "api" || got[1].Path != "dep" {
+		t.Errorf("RequiredImports(methods, conc) = %v, want [api dep]", paths(got))
+	}
+
+	// Rendering in the interface's own package needs only dep.
+	got = RequiredImports(methods, api)
+	if len(got) != 1 || got[0].Path != "dep" {
+		t.Errorf("RequiredImports(methods, api) = %v, want [dep]", paths(got))
+	}
+
+	// Builtins alone need nothing.
+	got = RequiredImports([]*types.Func{builtin}, concPkg)
+	if len(got) != 0 {
+		t.Errorf("RequiredImports(Builtin, conc) = %v, want none", paths(got))
+	}
+
+	// An instantiated generic such as box.List[item.Item] needs the
+	// type argument's package, not just the generic type's.
+	generic := api.Scope().Lookup("G").Type().Underlying().(*types.Interface)
+	got = RequiredImports([]*types.Func{generic.Method(0)}, concPkg)
+	if len(got) != 2 || got[0].Path != "box" || got[1].Path != "item" {
+		t.Errorf("RequiredImports(Boxed, conc) = %v, want [box item]", paths(got))
+	}
+}
+
+func paths(added []*AddedImport) []string {
+	var ps []string
+	for _, a := range added {
+		ps = append(ps, a.Path)
+	}
+	return ps
+}
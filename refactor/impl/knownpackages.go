@@ -0,0 +1,76 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"go/build"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/imports"
+)
+
+// KnownPackages returns, sorted, the canonical import paths of the
+// packages that can be imported from srcDir in the given build context.
+// Callers use it to offer candidate packages in which to look for
+// interfaces.
+//
+// Packages with no Go files compiled under the context's GOOS, GOARCH,
+// and build tags are excluded: importing such a package from a file
+// built in this configuration would not compile, so offering it as a
+// candidate is never useful.  A vendored package is reported by its
+// canonical path — the path a source file would import it by — and only
+// when it is the copy that an import from srcDir actually resolves to;
+// the same path provided by several vendor trees is listed once.  With
+// an empty srcDir, no vendor directory is visible and only unvendored
+// packages are reported.  If ctxt is nil, build.Default is used.
+//
+// The enumeration can be large, and interactive callers issue a fresh
+// request on every keystroke, so KnownPackages checks ctx between
+// packages and returns ctx.Err() promptly once it is cancelled.
+func KnownPackages(ctx context.Context, ctxt *build.Context, srcDir string) ([]string, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	var (
+		mu    sync.Mutex
+		seen  = make(map[string]bool)
+		paths []string
+	)
+	buildutil.ForEachPackage(ctxt, func(path string, err error) {
+		if err != nil || ctx.Err() != nil {
+			return
+		}
+		canonical := imports.VendorlessPath(path)
+		if canonical != path {
+			// A vendored copy: count it only if it is the one an
+			// import of the canonical path from srcDir resolves to,
+			// so the paths offered actually build for the requesting
+			// file.
+			bp, err := ctxt.Import(canonical, srcDir, 0)
+			if err != nil || bp.ImportPath != path || len(bp.GoFiles) == 0 {
+				return
+			}
+		} else {
+			bp, err := ctxt.Import(path, "", 0)
+			if err != nil || len(bp.GoFiles) == 0 {
+				return
+			}
+		}
+		mu.Lock()
+		if !seen[canonical] {
+			seen[canonical] = true
+			paths = append(paths, canonical)
+		}
+		mu.Unlock()
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestKnownPackages(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"a": {`package a`},
+		"b": {`// +build windows
+
+package b`},
+		"c": {`package c`},
+	})
+	ctxt.GOOS = "linux"
+
+	got, err := KnownPackages(context.Background(), ctxt, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"a": true, "c": true}
+	for _, path := range got {
+		if !want[path] {
+			t.Errorf("KnownPackages offered %q, which has no files for GOOS=linux", path)
+		}
+		delete(want, path)
+	}
+	for path := range want {
+		t.Errorf("KnownPackages missing %q", path)
+	}
+}
+
+func TestKnownPackagesCancellation(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"a": {`package a`},
+		"b": {`package b`},
+		"c": {`package c`},
+	})
+
+	// Cancel after the first directory is read, partway through the
+	// scan; the remaining per-package work must be skipped.
+	ctx, cancel := context.WithCancel(context.Background())
+	readDir := ctxt.ReadDir
+	ctxt.ReadDir = func(dir string) ([]os.FileInfo, error) {
+		cancel()
+		return readDir(dir)
+	}
+
+	paths, err := KnownPackages(ctx, ctxt, "")
+	if err != context.Canceled {
+		t.Fatalf("KnownPackages error = %v, want context.Canceled", err)
+	}
+	if paths != nil {
+		t.Errorf("KnownPackages returned %v after cancellation, want nil", paths)
+	}
+}
+
+// TestKnownPackagesVendorVariants checks that a path provided both at
+// the top level and by a vendor tree is listed once, by its canonical
+// path, and that a vendored copy invisible from srcDir is not offered.
+func TestKnownPackagesVendorVariants(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"proj/vendor":      {},
+		"proj/vendor/dep":  {`package dep`},
+		"proj/conc":        {`package conc`},
+		"other/vendor":     {},
+		"other/vendor/sec": {`package sec`},
+		"dep":              {`package dep`},
+	})
+
+	got, err := KnownPackages(context.Background(), ctxt, "/go/src/proj/conc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, path := range got {
+		counts[path]++
+	}
+	if counts["dep"] != 1 {
+		t.Errorf("got %d entries for %q, want 1 (paths: %v)", counts["dep"], "dep", got)
+	}
+	if counts["sec"] != 0 {
+		t.Errorf("offered %q, which does not resolve from proj/conc (paths: %v)", "sec", got)
+	}
+	if counts["proj/vendor/dep"] != 0 {
+		t.Errorf("offered the vendored path directly (paths: %v)", got)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import "go/token"
+
+// A MethodLocation pairs an interface method the concrete type has not
+// implemented with the position of the method's declaration in the
+// interface's source.
+type MethodLocation struct {
+	Name string
+	Pos  token.Position
+}
+
+// UnimplementedMethodLocations returns, for each method of the
+// interface the concrete type has yet to implement, the location where
+// the interface declares it.  Editors can show "N methods remaining"
+// on the type and navigate to each declaration; methods collected from
+// embedded interfaces point into whichever file and package declares
+// them.  Only the request's locating fields are consulted; the body
+// and ordering options have no effect.
+func UnimplementedMethodLocations(req *Request) ([]*MethodLocation, error) {
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.IfaceTypeArgs) > 0 {
+		if err := instantiateInterface(prog, it, req.ConcretePath, req.IfaceTypeArgs); err != nil {
+			return nil, err
+		}
+	}
+	ct, err := newConcreteType(prog, req)
+	if err != nil {
+		return nil, err
+	}
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	var locs []*MethodLocation
+	for _, m := range methods {
+		locs = append(locs, &MethodLocation{
+			Name: m.name,
+			Pos:  prog.Fset.Position(m.pos),
+		})
+	}
+	return locs, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnimplementedMethodLocations(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"base": {`package base
+
+type Closer interface {
+	Close() error
+}`},
+		"iface": {`package iface
+
+import "base"
+
+type Conn interface {
+	base.Closer
+	Open() error
+	Ping() error
+}`},
+		"conc": {`package conc
+
+type Client struct{}
+
+func (c *Client) Open() error { return nil }`},
+	})
+
+	locs, err := UnimplementedMethodLocations(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Conn",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Client",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 2 {
+		t.Fatalf("got %d locations %+v, want 2", len(locs), locs)
+	}
+	byName := make(map[string]*MethodLocation)
+	for _, l := range locs {
+		byName[l.Name] = l
+	}
+	if l := byName["Close"]; l == nil || !strings.HasPrefix(l.Pos.Filename, "/go/src/base/") {
+		t.Errorf("Close location = %+v, want the embedded interface's package", l)
+	}
+	if l := byName["Ping"]; l == nil || !strings.HasPrefix(l.Pos.Filename, "/go/src/iface/") || l.Pos.Line != 8 {
+		t.Errorf("Ping location = %+v, want iface line 8", l)
+	}
+}
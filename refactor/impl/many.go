@@ -0,0 +1,96 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"go/build"
+	"go/types"
+	"path"
+	"sort"
+
+	"golang.org/x/tools/go/buildutil"
+	"golang.org/x/tools/go/loader"
+)
+
+// A ConcreteSpec names one concrete type for ImplementMany.  The
+// generated methods take a pointer receiver.
+type ConcreteSpec struct {
+	Path string // import path of the type's package
+	Name string // the type's name within it
+}
+
+// A FileChange is the complete new contents of one file touched by
+// ImplementMany.
+type FileChange struct {
+	Filename string
+	Contents []byte
+}
+
+// ImplementMany stubs several concrete types against the one interface
+// the request names, the common chore after adding a method to an
+// interface with many implementers.  The request supplies the
+// interface, build context, and body options; its concrete-type
+// fields are derived from each spec in turn.  Generation for each type
+// runs against an overlay carrying the previous results, so types
+// declared in the same file accumulate correctly.  The changed files
+// are returned sorted by name; any type failing to generate fails the
+// whole call, leaving the caller's files untouched.
+func ImplementMany(req *Request, concretes []*ConcreteSpec) ([]*FileChange, error) {
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+
+	// One permissive load finds each type's declaring file; the types
+	// are then stubbed one at a time.
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	for _, c := range concretes {
+		conf.Import(c.Path)
+	}
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	overlay := make(map[string][]byte)
+	work := buildutil.OverlayContext(ctxt, overlay)
+	modified := make(map[string][]byte)
+	for _, c := range concretes {
+		info := prog.Package(c.Path)
+		if info == nil {
+			return nil, fmt.Errorf("package %q not found", c.Path)
+		}
+		obj, ok := info.Pkg.Scope().Lookup(c.Name).(*types.TypeName)
+		if !ok {
+			return nil, fmt.Errorf("no type named %s in package %q", c.Name, c.Path)
+		}
+		filename := prog.Fset.Position(obj.Pos()).Filename
+
+		r := *req
+		r.Ctxt = work
+		r.ConcretePath = c.Path
+		r.File = path.Base(filename)
+		r.Target = "*" + c.Name
+		r.AllowErrors = true // intermediate states need not compile
+		resp, err := Implement(&r)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", c.Path, c.Name, err)
+		}
+		overlay[filename] = resp.File
+		modified[filename] = resp.File
+	}
+
+	var changes []*FileChange
+	for filename, contents := range modified {
+		changes = append(changes, &FileChange{Filename: filename, Contents: contents})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Filename < changes[j].Filename })
+	return changes, nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImplementMany(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {
+			`package conc
+
+type A struct{}`,
+			`package conc
+
+type B struct{}`,
+		},
+	})
+
+	changes, err := ImplementMany(&Request{
+		Ctxt:      ctxt,
+		IfacePath: "iface",
+		IfaceName: "Runner",
+	}, []*ConcreteSpec{
+		{Path: "conc", Name: "A"},
+		{Path: "conc", Name: "B"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("got %d changed files %+v, want 2", len(changes), changes)
+	}
+	if changes[0].Filename != "/go/src/conc/0.go" || changes[1].Filename != "/go/src/conc/1.go" {
+		t.Fatalf("changed files = %s, %s", changes[0].Filename, changes[1].Filename)
+	}
+	if !strings.Contains(string(changes[0].Contents), "func (a *A) Run() error {") {
+		t.Errorf("A not stubbed:\n%s", changes[0].Contents)
+	}
+	if !strings.Contains(string(changes[1].Contents), "func (b *B) Run() error {") {
+		t.Errorf("B not stubbed:\n%s", changes[1].Contents)
+	}
+}
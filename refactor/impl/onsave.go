@@ -0,0 +1,112 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"go/build"
+	"path/filepath"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+// SaveOptions configures the optional work done when a file is saved.
+// The zero value does nothing: every save-time fix is opt-in.
+type SaveOptions struct {
+	// ImplementAssertions, when set, scans the saved file for blank
+	// interface assertions (var _ I = (*T)(nil)) whose concrete type
+	// is missing methods and generates the stubs automatically, so
+	// the file compiles after every save.
+	ImplementAssertions bool
+}
+
+// OnSave applies the enabled save-time fixes to the named file of the
+// package and returns the resulting change, or nil if there is
+// nothing to do.  Fixes only ever add methods and imports; existing
+// code is never removed or rewritten.
+//
+// Several assertions may need stubs, and generating one set can only
+// be done against contents that include the previous set, so OnSave
+// iterates with the intermediate results overlaid on the build
+// context until no missing assertion remains in the file.
+func OnSave(ctxt *build.Context, opts SaveOptions, pkgPath, file string) (*Response, error) {
+	if !opts.ImplementAssertions {
+		return nil, nil
+	}
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+
+	overlay := make(map[string][]byte)
+	work := buildutil.OverlayContext(ctxt, overlay)
+	var filename string
+	var final []byte
+	for {
+		assertions, err := FindAssertions(work, pkgPath)
+		if err != nil {
+			return nil, err
+		}
+		var todo *Assertion
+		for _, a := range assertions {
+			// Speculative assertions come from x.(I) and type-switch
+			// cases; stubbing those would change what the program
+			// does at run time, not repair a compile error.
+			if a.Speculative {
+				continue
+			}
+			// The concrete type may be declared in another package,
+			// as in var _ iface.Runner = other.New(); its stubs
+			// belong there, not in the saved file.
+			if a.ConcretePath != pkgPath {
+				continue
+			}
+			if a.Missing && filepath.Base(a.Pos.Filename) == file {
+				todo = a
+				break
+			}
+		}
+		if todo == nil {
+			break
+		}
+		filename = todo.Pos.Filename
+		before, err := readFile(work, filename)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := Implement(&Request{
+			Ctxt:          work,
+			IfacePath:     todo.IfacePath,
+			IfaceName:     todo.IfaceName,
+			IfaceTypeArgs: todo.IfaceTypeArgs,
+			ConcretePath:  pkgPath,
+			File:          file,
+			Target:        todo.Target,
+			AllowErrors:   true,
+			Granular:      true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// An assertion can stay unsatisfied while Implement has
+		// nothing to add — a method of the right name with a drifted
+		// signature, say — so an iteration that changes nothing will
+		// never make progress.  Stop rather than spin; a save hook
+		// must always return.
+		if bytes.Equal(resp.File, before) {
+			break
+		}
+		final = resp.File
+		overlay[filename] = resp.File
+	}
+	if final == nil {
+		return nil, nil
+	}
+
+	orig, err := readFile(ctxt, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{File: final, Edits: computeEdits(orig, final)}, nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOnSave(t *testing.T) {
+	orig := `package conc
+
+import "iface"
+
+type Clock struct{}
+
+var _ iface.Waiter = (*Clock)(nil)
+var _ iface.Ticker = (*Clock)(nil)
+`
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Waiter interface {
+	Wait() error
+}
+
+type Ticker interface {
+	Tick() int
+}`},
+		"conc": {orig},
+	})
+
+	// Off by default: the zero options must not touch the file.
+	resp, err := OnSave(ctxt, SaveOptions{}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatalf("OnSave with zero options returned a change: %+v", resp)
+	}
+
+	resp, err = OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("OnSave returned nil, want generated stubs")
+	}
+	src := string(resp.File)
+	for _, want := range []string{
+		"func (c *Clock) Wait() error {",
+		"func (c *Clock) Tick() int {",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("output missing %q:\n%s", want, src)
+		}
+	}
+	// Only additions: the original contents must survive intact.
+	if !strings.Contains(src, orig[:len(orig)-1]) {
+		t.Errorf("original contents were modified:\n%s", src)
+	}
+	for _, e := range resp.Edits {
+		if e.Start != e.End {
+			t.Errorf("edit [%d,%d) removes text; OnSave must only insert", e.Start, e.End)
+		}
+	}
+}
+
+// TestOnSaveWrongSignature is a regression test: a method with the
+// right name but a drifted signature leaves the assertion failing while
+// Implement has nothing to add, and OnSave used to loop on that
+// forever.  It must return with no change instead.
+func TestOnSaveWrongSignature(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run(count int) error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Job struct{}
+
+func (j *Job) Run() {}
+
+var _ iface.Runner = (*Job)(nil)`},
+	})
+
+	resp, err := OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatalf("OnSave returned a change for a signature drift it cannot fix:\n%s", resp.File)
+	}
+}
+
+// TestOnSaveGenericInterface checks that an assertion instantiating a
+// generic interface carries its type arguments into the generated
+// request, rather than failing the whole save.
+func TestOnSaveGenericInterface(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Handler[T any] interface {
+	Handle(v T) error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Event struct{}
+
+type Processor struct{}
+
+var _ iface.Handler[Event] = (*Processor)(nil)`},
+	})
+
+	resp, err := OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("OnSave returned nil, want stubs for the generic assertion")
+	}
+	if src := string(resp.File); !strings.Contains(src, "func (p *Processor) Handle(v Event) error {") {
+		t.Errorf("type argument not forwarded to the stub:\n%s", src)
+	}
+}
+
+// TestOnSaveSkipsSpeculative checks that a run-time probe like
+// _, ok := y.(Handler) never triggers stub generation on save: adding
+// the methods would silently flip the probe's answer.
+func TestOnSaveSkipsSpeculative(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Handler interface {
+	Handle() error
+}
+
+type Job struct{}
+
+func probe() bool {
+	var y interface{}
+	y = Job{}
+	_, ok := y.(Handler)
+	return ok
+}`},
+	})
+
+	resp, err := OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatalf("OnSave stubbed a speculative assertion:\n%s", resp.File)
+	}
+}
+
+// TestOnSaveSkipsForeignType checks that an assertion whose concrete
+// type lives in another package is left alone: the stubs would have to
+// go where the type is declared, which is not the file being saved.
+func TestOnSaveSkipsForeignType(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"other": {`package other
+
+type Thing struct{}
+
+func New() *Thing { return &Thing{} }`},
+		"conc": {`package conc
+
+import (
+	"iface"
+	"other"
+)
+
+var _ iface.Runner = other.New()`},
+	})
+
+	resp, err := OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatalf("OnSave acted on a foreign concrete type:\n%s", resp.File)
+	}
+}
+
+// TestOnSaveValueReceiver checks that a value assertion generates value
+// receivers, and a pointer assertion pointer receivers, through the
+// save path.
+func TestOnSaveValueReceiver(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}
+
+type Stopper interface {
+	Stop() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Val struct{}
+
+type Ptr struct{}
+
+var _ iface.Runner = Val{}
+var _ iface.Stopper = &Ptr{}`},
+	})
+
+	resp, err := OnSave(ctxt, SaveOptions{ImplementAssertions: true}, "conc", "0.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("OnSave returned nil, want a change")
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (v Val) Run() error {") {
+		t.Errorf("value assertion did not generate a value receiver:\n%s", src)
+	}
+	if !strings.Contains(src, "func (p *Ptr) Stop() error {") {
+		t.Errorf("pointer assertion did not generate a pointer receiver:\n%s", src)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"strings"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+// Preview computes the stubs for the request and renders the resulting
+// change as a unified diff, without writing anything.  The response's
+// Edits describe the change as position-anchored replacements, so a
+// client can show the diff for confirmation and apply the edits itself
+// once the user accepts; generation is thereby decoupled from
+// application, which is friendlier for large changes.
+func Preview(req *Request) (*Response, string, error) {
+	resp, err := Implement(req)
+	if err != nil {
+		return nil, "", err
+	}
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	bp, err := ctxt.Import(req.ConcretePath, "", build.FindOnly)
+	if err != nil {
+		return nil, "", err
+	}
+	filename := buildutil.JoinPath(ctxt, bp.Dir, req.File)
+	orig, err := readFile(ctxt, filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot read %s: %v", filename, err)
+	}
+	return resp, unifiedDiff(req.File, orig, resp.File), nil
+}
+
+// unifiedDiff renders the change from old to new as a single-hunk
+// unified diff.  Like computeEdits, it isolates the differing middle
+// region between the longest common prefix and suffix, here extended to
+// line boundaries.  It returns "" if the contents are equal.
+func unifiedDiff(name string, old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	oldLines := strings.SplitAfter(string(old), "\n")
+	newLines := strings.SplitAfter(string(new), "\n")
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	oldN := len(oldLines) - prefix - suffix
+	newN := len(newLines) - prefix - suffix
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", prefix+1, oldN, prefix+1, newN)
+	for _, line := range oldLines[prefix : len(oldLines)-suffix] {
+		b.WriteString("-" + strings.TrimSuffix(line, "\n") + "\n")
+	}
+	for _, line := range newLines[prefix : len(newLines)-suffix] {
+		b.WriteString("+" + strings.TrimSuffix(line, "\n") + "\n")
+	}
+	return b.String()
+}
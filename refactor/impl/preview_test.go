@@ -0,0 +1,55 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreview(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Job struct{}`},
+	})
+
+	resp, diff, err := Preview(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The change is proposed, not applied: the edits come back for the
+	// client to apply after confirmation.
+	if len(resp.Edits) == 0 {
+		t.Fatal("Preview returned no edits")
+	}
+	if !strings.HasPrefix(diff, "--- a/0.go\n+++ b/0.go\n@@ ") {
+		t.Errorf("diff lacks unified header:\n%s", diff)
+	}
+	if !strings.Contains(diff, "+func (j *Job) Run() error {") {
+		t.Errorf("diff does not show the added stub:\n%s", diff)
+	}
+	if strings.Contains(diff, "-package conc") {
+		t.Errorf("diff removes unchanged lines:\n%s", diff)
+	}
+}
+
+func TestUnifiedDiffEqual(t *testing.T) {
+	if diff := unifiedDiff("f.go", []byte("a\nb\n"), []byte("a\nb\n")); diff != "" {
+		t.Errorf("diff of equal contents = %q, want empty", diff)
+	}
+}
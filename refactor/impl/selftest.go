@@ -0,0 +1,57 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/buildutil"
+)
+
+// A SelfTestResult reports whether the stub-generation machinery is
+// working.  Support tooling attaches it to bug reports so that
+// environment problems — a broken GOPATH, an unreadable GOROOT — can be
+// told apart from bugs in the generator itself.
+type SelfTestResult struct {
+	OK    bool   // generation succeeded and produced the expected stub
+	Error string // the failure, when OK is false
+}
+
+// SelfTest runs the generator against a known-good synthetic
+// interface/type pair held in an in-memory build context, so the check
+// is independent of the user's workspace.  A healthy installation
+// always succeeds; a failure therefore points at the generator rather
+// than the environment.  The check is cheap but not free, so clients
+// should run it on demand — when assembling a bug report — rather than
+// routinely.
+func SelfTest() *SelfTestResult {
+	ctxt := buildutil.FakeContext(map[string]map[string]string{
+		"iface": {"iface.go": `package iface
+
+type Runner interface {
+	Run(count int) error
+}`},
+		"conc": {"conc.go": `package conc
+
+type Job struct{}`},
+	})
+	resp, err := Implement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "conc.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		return &SelfTestResult{Error: err.Error()}
+	}
+	want := "func (j *Job) Run(count int) error {"
+	if !strings.Contains(string(resp.File), want) {
+		return &SelfTestResult{Error: fmt.Sprintf("generated file lacks %q:\n%s", want, resp.File)}
+	}
+	return &SelfTestResult{OK: true}
+}
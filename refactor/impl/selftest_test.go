@@ -0,0 +1,13 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import "testing"
+
+func TestSelfTest(t *testing.T) {
+	if res := SelfTest(); !res.OK {
+		t.Errorf("self-test failed: %s", res.Error)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// The strategies an ImplementPerMethod caller may choose for a method.
+const (
+	// StrategyStub generates a panicking stub, as Implement does.
+	StrategyStub = "stub"
+
+	// StrategyForward generates a method that delegates to the struct
+	// field embedding the interface.
+	StrategyForward = "forward"
+
+	// StrategySkip generates nothing for the method, relying on its
+	// promotion from the embedded field.
+	StrategySkip = "skip"
+)
+
+// ImplementPerMethod generates the interface's methods with a strategy
+// chosen method by method, for clients that presented the method list
+// to the user and collected a choice for each: a panicking stub,
+// forwarding to the field embedding the interface, or skipping the
+// method to rely on promotion.  Methods absent from strategies get the
+// panic stub, and methods the concrete type already declares explicitly
+// are never regenerated.  The forward and skip strategies require the
+// concrete struct to embed the interface, as they are meaningless
+// without a field to delegate to.
+func ImplementPerMethod(req *Request, strategies map[string]string) (*Response, error) {
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.IfaceTypeArgs) > 0 {
+		if err := instantiateInterface(prog, it, req.ConcretePath, req.IfaceTypeArgs); err != nil {
+			return nil, err
+		}
+	}
+	ct, err := newConcreteType(prog, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The complete method set bounds what a strategy may name, whether
+	// or not the method still needs generating.
+	ifaceMethods := make(map[string]bool)
+	if obj, ok := it.pkg.Pkg.Scope().Lookup(req.IfaceName).(*types.TypeName); ok {
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumMethods(); i++ {
+				ifaceMethods[iface.Method(i).Name()] = true
+			}
+		}
+	}
+	needsField := false
+	for name, strat := range strategies {
+		if !ifaceMethods[name] {
+			return nil, fmt.Errorf("interface %s has no method %s", req.IfaceName, name)
+		}
+		switch strat {
+		case StrategyStub:
+		case StrategyForward, StrategySkip:
+			needsField = true
+		default:
+			return nil, fmt.Errorf("unknown strategy %q for method %s; expected %q, %q, or %q",
+				strat, name, StrategyStub, StrategyForward, StrategySkip)
+		}
+	}
+	if needsField {
+		if st, ok := ct.typ.Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				f := st.Field(i)
+				if !f.Anonymous() {
+					continue
+				}
+				if named, ok := f.Type().(*types.Named); ok &&
+					named.Obj().Pkg() != nil &&
+					named.Obj().Pkg().Path() == req.IfacePath &&
+					named.Obj().Name() == req.IfaceName {
+					ct.forwardField = f.Name()
+					break
+				}
+			}
+		}
+		if ct.forwardField == "" {
+			return nil, fmt.Errorf("%s does not embed %s, which the forward and skip strategies rely on",
+				types.TypeString(ct.typ, nil), req.IfaceName)
+		}
+	}
+
+	// Methods promoted from the embedded field still count as missing —
+	// the user chose what to do with each — but methods the type
+	// declares explicitly are its own.
+	ct.explicitOverEmbedded = true
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+	kept := methods[:0]
+	for _, m := range methods {
+		switch strategies[m.name] {
+		case StrategySkip:
+			continue
+		case StrategyForward:
+			nameParams(m.sig)
+		}
+		kept = append(kept, m)
+	}
+	methods = kept
+	if err := ct.checkFieldCollisions(methods); err != nil {
+		return nil, err
+	}
+
+	ct.strategies = strategies
+	return ct.sourceWithStubs(methods, defaultTemplate)
+}
@@ -0,0 +1,95 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImplementPerMethod(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Store interface {
+	Get(k string) (string, error)
+	Put(k, v string) error
+	Close() error
+	Len() int
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Wrapper struct {
+	iface.Store
+}`},
+	})
+
+	resp, err := ImplementPerMethod(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Store",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "w *Wrapper",
+	}, map[string]string{
+		"Get":   StrategyForward,
+		"Put":   StrategyStub,
+		"Close": StrategySkip,
+		// Len is unmentioned and defaults to a stub.
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := string(resp.File)
+	if !strings.Contains(src, "func (w *Wrapper) Get(k string) (string, error) {\n\treturn w.Store.Get(k)\n}") {
+		t.Errorf("Get not forwarded:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Wrapper) Put(k, v string) error {\n\tpanic(\"not implemented\")") {
+		t.Errorf("Put not stubbed:\n%s", src)
+	}
+	if strings.Contains(src, "Close(") {
+		t.Errorf("skipped method generated:\n%s", src)
+	}
+	if !strings.Contains(src, "func (w *Wrapper) Len() int {\n\tpanic(\"not implemented\")") {
+		t.Errorf("unmentioned method not stubbed:\n%s", src)
+	}
+}
+
+func TestImplementPerMethodErrors(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"conc": {`package conc
+
+type Job struct{}`},
+	})
+	req := &Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Runner",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+	}
+
+	_, err := ImplementPerMethod(req, map[string]string{"Stop": StrategyStub})
+	if err == nil || !strings.Contains(err.Error(), "no method Stop") {
+		t.Errorf("err = %v, want unknown-method rejection", err)
+	}
+	_, err = ImplementPerMethod(req, map[string]string{"Run": "delegate"})
+	if err == nil || !strings.Contains(err.Error(), `unknown strategy "delegate"`) {
+		t.Errorf("err = %v, want unknown-strategy rejection", err)
+	}
+	// Job does not embed Runner, so forwarding has nothing to call.
+	_, err = ImplementPerMethod(req, map[string]string{"Run": StrategyForward})
+	if err == nil || !strings.Contains(err.Error(), "does not embed") {
+		t.Errorf("err = %v, want missing-embed rejection", err)
+	}
+}
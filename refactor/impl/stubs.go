@@ -0,0 +1,110 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// DefaultStubMessage is the panic message the default template writes
+// into generated bodies.  FindUnimplementedStubs matches it when the
+// caller does not supply a message of its own.
+const DefaultStubMessage = "not implemented"
+
+// A StubSite is a function or method whose body is still the generated
+// placeholder.  Editors can surface them as diagnostics, and CI can
+// fail while any remain.
+type StubSite struct {
+	Pos  token.Position // position of the declaration
+	Name string         // the function or method name
+	Recv string         // printed receiver type; "" for a plain function
+}
+
+// FindUnimplementedStubs scans the package for functions and methods
+// whose entire body is the single statement
+//
+//	panic("not implemented")
+//
+// that is, stubs that were generated but never filled in.  message, if
+// non-empty, replaces "not implemented" as the string to match, so
+// callers that generate bodies with MarkTODO or a custom template can
+// look for their own marker; a match then only requires the panic
+// argument to start with the message, since the TODO form appends the
+// method name.  The package is loaded with type errors tolerated — a
+// half-implemented package is precisely the interesting case.
+func FindUnimplementedStubs(ctxt *build.Context, pkgPath, message string) ([]*StubSite, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	if message == "" {
+		message = DefaultStubMessage
+	}
+	conf := loader.Config{
+		Build:       ctxt,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(pkgPath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+	info := prog.Package(pkgPath)
+
+	var sites []*StubSite
+	for _, f := range info.Files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !isStubBody(fn.Body, message) {
+				continue
+			}
+			site := &StubSite{
+				Pos:  prog.Fset.Position(fn.Pos()),
+				Name: fn.Name.Name,
+			}
+			if fn.Recv != nil && len(fn.Recv.List) == 1 {
+				site.Recv = types.ExprString(fn.Recv.List[0].Type)
+			}
+			sites = append(sites, site)
+		}
+	}
+	return sites, nil
+}
+
+// isStubBody reports whether body consists of the single statement
+// panic("<message>"), or panic("<message>...") to cover bodies whose
+// marker embeds the method name.
+func isStubBody(body *ast.BlockStmt, message string) bool {
+	if body == nil || len(body.List) != 1 {
+		return false
+	}
+	expr, ok := body.List[0].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := expr.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	if fun, ok := call.Fun.(*ast.Ident); !ok || fun.Name != "panic" {
+		return false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return false
+	}
+	arg, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(arg, message)
+}
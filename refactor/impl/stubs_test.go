@@ -0,0 +1,71 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import "testing"
+
+func TestFindUnimplementedStubs(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Job struct{}
+
+func (j *Job) Run() error {
+	panic("not implemented") // TODO: Implement
+}
+
+func (j *Job) Stop() error {
+	return nil
+}
+
+func helper() {
+	panic("not implemented")
+}
+
+func wrapped() {
+	if true {
+		panic("not implemented")
+	}
+}`},
+	})
+
+	got, err := FindUnimplementedStubs(ctxt, "conc", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d stubs %+v, want 2", len(got), got)
+	}
+	if got[0].Name != "Run" || got[0].Recv != "*Job" {
+		t.Errorf("stubs[0] = %+v, want Run on *Job", got[0])
+	}
+	if got[1].Name != "helper" || got[1].Recv != "" {
+		t.Errorf("stubs[1] = %+v, want the helper function", got[1])
+	}
+}
+
+func TestFindUnimplementedStubsTODO(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"conc": {`package conc
+
+type Job struct{}
+
+func (j *Job) Run() error {
+	panic("TODO: implement Run")
+}
+
+func (j *Job) Stop() error {
+	panic("not implemented")
+}`},
+	})
+
+	got, err := FindUnimplementedStubs(ctxt, "conc", "TODO: implement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Name != "Run" {
+		t.Fatalf("got %+v, want only Run", got)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"go/build"
+	"go/parser"
+	"go/types"
+	"path"
+	"sort"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// A SymbolCandidate identifies a type that matched a symbol query, so
+// an ambiguous query can be disambiguated by the user.
+type SymbolCandidate struct {
+	Path string // import path of the declaring package
+	Name string // the type's name
+	File string // base name of the declaring file
+}
+
+// ImplementBySymbol resolves the concrete type by name across the given
+// packages and runs the implement flow against it, deriving the
+// request's ConcretePath, File, and Target from where the type was
+// found.  Command-palette flows use this so the user can name the type
+// without having its declaring file open; the receiver defaults to a
+// pointer, as Implement's callers conventionally want.  When more than
+// one package declares a matching type, the candidates are returned
+// instead of a response, for the caller to present as choices; the
+// request's IfacePath, IfaceName, and generation options are used as
+// given.
+func ImplementBySymbol(req *Request, symbol string, pkgPaths []string) (*Response, []*SymbolCandidate, error) {
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	// The packages are searched permissively: an unrelated error in one
+	// of them must not hide the symbol.
+	conf := loader.Config{
+		Build:       ctxt,
+		ParserMode:  parser.ParseComments,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	for _, p := range pkgPaths {
+		conf.Import(p)
+	}
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []*SymbolCandidate
+	for _, p := range pkgPaths {
+		info := prog.Package(p)
+		if info == nil {
+			continue
+		}
+		obj, ok := info.Pkg.Scope().Lookup(symbol).(*types.TypeName)
+		if !ok || types.IsInterface(obj.Type()) {
+			continue
+		}
+		candidates = append(candidates, &SymbolCandidate{
+			Path: p,
+			Name: symbol,
+			File: path.Base(prog.Fset.Position(obj.Pos()).Filename),
+		})
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, nil, fmt.Errorf("no concrete type named %s in the given packages", symbol)
+	case 1:
+		c := candidates[0]
+		r := *req
+		r.ConcretePath = c.Path
+		r.File = c.File
+		r.Target = "*" + c.Name
+		resp, err := Implement(&r)
+		return resp, nil, err
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Path < candidates[j].Path })
+	return nil, candidates, nil
+}
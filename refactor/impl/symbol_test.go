@@ -0,0 +1,78 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImplementBySymbol(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"a": {`package a
+
+type Job struct{}`},
+		"b": {`package b
+
+type Worker struct{}`},
+	})
+	req := &Request{
+		Ctxt:      ctxt,
+		IfacePath: "iface",
+		IfaceName: "Runner",
+	}
+
+	resp, candidates, err := ImplementBySymbol(req, "Job", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates != nil {
+		t.Fatalf("unexpected candidates for a unique symbol: %v", candidates)
+	}
+	if !strings.Contains(string(resp.File), "func (j *Job) Run() error {") {
+		t.Errorf("stub not generated:\n%s", resp.File)
+	}
+
+	_, _, err = ImplementBySymbol(req, "Missing", []string{"a", "b"})
+	if err == nil || !strings.Contains(err.Error(), "no concrete type named Missing") {
+		t.Errorf("err = %v, want no-symbol rejection", err)
+	}
+}
+
+func TestImplementBySymbolAmbiguous(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run() error
+}`},
+		"a": {`package a
+
+type Job struct{}`},
+		"b": {`package b
+
+type Job struct{}`},
+	})
+
+	resp, candidates, err := ImplementBySymbol(&Request{
+		Ctxt:      ctxt,
+		IfacePath: "iface",
+		IfaceName: "Runner",
+	}, "Job", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Fatal("response generated despite ambiguity")
+	}
+	if len(candidates) != 2 || candidates[0].Path != "a" || candidates[1].Path != "b" {
+		t.Fatalf("candidates = %v, want one per package in path order", candidates)
+	}
+}
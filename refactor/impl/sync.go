@@ -0,0 +1,201 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/loader"
+)
+
+// DefaultSyncMarker is the comment marker SyncStubs looks for when the
+// caller does not configure one.  A stub annotated
+//
+//	func (t *T) Run() error { // implements iface.Runner
+//
+// is correlated with that interface.
+const DefaultSyncMarker = "implements "
+
+// SyncStubs scans the package for panic-stub methods annotated with a
+// trailing marker comment naming the interface they were generated
+// for, and rewrites the signature of any whose interface has since
+// changed, leaving the body bytes untouched.  The interface is spelled
+// as the file would qualify it — iface.Runner, or a bare name for the
+// same package.  Markers that no longer resolve are skipped rather
+// than failing the run, since the package may be mid-edit; so are
+// methods the interface no longer declares.  The changed files are
+// returned sorted by name, with their complete new contents.
+func SyncStubs(ctxt *build.Context, pkgPath, marker string) ([]*FileChange, error) {
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	if marker == "" {
+		marker = DefaultSyncMarker
+	}
+	conf := loader.Config{
+		Build:       ctxt,
+		ParserMode:  parser.ParseComments,
+		AllowErrors: true,
+		TypeChecker: types.Config{Error: func(error) {}},
+	}
+	conf.Import(pkgPath)
+	prog, err := conf.Load()
+	if err != nil {
+		return nil, err
+	}
+	info := prog.Package(pkgPath)
+
+	type edit struct {
+		start, end int // byte offsets into the file
+		text       string
+	}
+	edits := make(map[string][]edit)
+	for _, f := range info.Files {
+		tf := prog.Fset.File(f.Pos())
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 || !isStubBody(fn.Body, "") {
+				continue
+			}
+			iface := markerInterface(prog.Fset, f, fn, marker)
+			if iface == "" {
+				continue
+			}
+			ipath, iname := pkgPath, iface
+			if i := strings.LastIndex(iface, "."); i >= 0 {
+				iname = iface[i+1:]
+				ipath = ""
+				for _, imp := range info.Pkg.Imports() {
+					if imp.Name() == iface[:i] {
+						ipath = imp.Path()
+						break
+					}
+				}
+				if ipath == "" {
+					continue
+				}
+			}
+
+			want, err := interfaceSignature(prog, ctxt, ipath, iname, pkgPath, prog.Fset.Position(fn.Pos()).Filename, fn)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fn.Name.Name, err)
+			}
+			if want == "" {
+				continue // marker or method did not resolve
+			}
+			start := prog.Fset.Position(fn.Name.End()).Offset
+			end := prog.Fset.Position(fn.Body.Lbrace).Offset
+			filename := tf.Name()
+			src, err := readSyncFile(ctxt, filename)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(string(src[start:end])) == strings.TrimSpace(want) {
+				continue // already in sync
+			}
+			edits[filename] = append(edits[filename], edit{start, end, want + " "})
+		}
+	}
+
+	var changes []*FileChange
+	for filename, list := range edits {
+		src, err := readSyncFile(ctxt, filename)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].start > list[j].start })
+		for _, e := range list {
+			src = append(src[:e.start], append([]byte(e.text), src[e.end:]...)...)
+		}
+		changes = append(changes, &FileChange{Filename: filename, Contents: src})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Filename < changes[j].Filename })
+	return changes, nil
+}
+
+// markerInterface returns the interface named by a marker comment on
+// the declaration's first line, or "".
+func markerInterface(fset *token.FileSet, f *ast.File, fn *ast.FuncDecl, marker string) string {
+	line := fset.Position(fn.Pos()).Line
+	for _, cg := range f.Comments {
+		if cg.Pos() < fn.Pos() || fset.Position(cg.Pos()).Line != line {
+			continue
+		}
+		text := strings.TrimSpace(strings.TrimPrefix(cg.List[0].Text, "//"))
+		if strings.HasPrefix(text, marker) {
+			return strings.TrimSpace(strings.TrimPrefix(text, marker))
+		}
+	}
+	return ""
+}
+
+// interfaceSignature renders, as the stub's file would spell it, the
+// signature the interface currently declares for the method, without a
+// leading "func".  It returns "" when the interface or the method
+// cannot be found.
+func interfaceSignature(prog *loader.Program, ctxt *build.Context, ipath, iname, concretePath, filename string, fn *ast.FuncDecl) (string, error) {
+	it, err := findInterface(prog, ipath, iname)
+	if err != nil {
+		return "", nil
+	}
+	var recv bytes.Buffer
+	if err := printer.Fprint(&recv, prog.Fset, fn.Recv.List[0].Type); err != nil {
+		return "", err
+	}
+	target := recv.String()
+	if len(fn.Recv.List[0].Names) == 1 {
+		target = fn.Recv.List[0].Names[0].Name + " " + target
+	}
+	ct, err := newConcreteType(prog, &Request{
+		Ctxt:         ctxt,
+		ConcretePath: concretePath,
+		File:         path.Base(filename),
+		Target:       target,
+	})
+	if err != nil {
+		return "", err
+	}
+	ct.includeAll = true
+	methods, err := ct.missingMethods(it, make(map[string]bool), make(map[string]bool))
+	if err != nil {
+		return "", err
+	}
+	for _, m := range methods {
+		if m.name != fn.Name.Name {
+			continue
+		}
+		var sig bytes.Buffer
+		if err := printer.Fprint(&sig, token.NewFileSet(), m.sig); err != nil {
+			return "", err
+		}
+		return strings.TrimPrefix(sig.String(), "func"), nil
+	}
+	return "", nil
+}
+
+// readSyncFile reads a file through the build context, so overlays and
+// fake file systems are honored.
+func readSyncFile(ctxt *build.Context, filename string) ([]byte, error) {
+	if ctxt.OpenFile != nil {
+		r, err := ctxt.OpenFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return ioutil.ReadFile(filename)
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSyncStubs(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Runner interface {
+	Run(name string, count int) error
+	Stop() error
+}`},
+		"conc": {`package conc
+
+import "iface"
+
+type Job struct{}
+
+func (j *Job) Run(name string) error { // implements iface.Runner
+	panic("not implemented") // TODO: Implement
+}
+
+func (j *Job) Stop() error { // implements iface.Runner
+	panic("not implemented") // TODO: Implement
+}
+
+func (j *Job) Other() error {
+	panic("not implemented") // TODO: Implement
+}`},
+	})
+
+	changes, err := SyncStubs(ctxt, "conc", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changed files %+v, want 1", len(changes), changes)
+	}
+	src := string(changes[0].Contents)
+	if !strings.Contains(src, "func (j *Job) Run(name string, count int) error { // implements iface.Runner") {
+		t.Errorf("drifted signature not corrected:\n%s", src)
+	}
+	// Stop matches the interface and Other has no marker; neither may
+	// change.
+	if !strings.Contains(src, "func (j *Job) Stop() error { // implements iface.Runner") {
+		t.Errorf("in-sync stub rewritten:\n%s", src)
+	}
+	if !strings.Contains(src, "func (j *Job) Other() error {\n") {
+		t.Errorf("unmarked method rewritten:\n%s", src)
+	}
+}
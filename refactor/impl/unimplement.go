@@ -0,0 +1,123 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/imports"
+)
+
+// Unimplement is the inverse of Implement: it removes from the
+// request's file the methods previously generated for the concrete
+// type against the interface, and prunes any imports that become
+// unused as a result.  Only methods that are still unmodified panic
+// stubs are removed — a stub the user has since filled in is real code
+// and is left alone, as is any method the interface does not declare.
+// The response carries the file's new contents and the edits that
+// produce them; it is nil when there was nothing to remove.
+func Unimplement(req *Request) (*Response, error) {
+	prog, err := loadRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	it, err := findInterface(prog, req.IfacePath, req.IfaceName)
+	if err != nil {
+		return nil, err
+	}
+	ct, err := newConcreteType(prog, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The complete method set, promoted methods included, bounds what
+	// may be removed.
+	ifaceMethods := make(map[string]bool)
+	if obj, ok := it.pkg.Pkg.Scope().Lookup(req.IfaceName).(*types.TypeName); ok {
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			for i := 0; i < iface.NumMethods(); i++ {
+				ifaceMethods[iface.Method(i).Name()] = true
+			}
+		}
+	}
+
+	tname := strings.TrimPrefix(strings.Fields(ct.recv)[1], "*")
+	if i := strings.Index(tname, "["); i >= 0 {
+		tname = tname[:i]
+	}
+
+	ctxt := req.Ctxt
+	if ctxt == nil {
+		ctxt = &build.Default
+	}
+	filename := prog.Fset.Position(ct.file.Pos()).Filename
+	orig, err := readFile(ctxt, filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", filename, err)
+	}
+
+	type span struct{ start, end int }
+	var spans []span
+	for _, decl := range ct.file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+			continue
+		}
+		if !ifaceMethods[fn.Name.Name] || !isStubBody(fn.Body, "") {
+			continue
+		}
+		t := fn.Recv.List[0].Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		// A generic receiver spells its type parameters, as in
+		// (c *Cache[T]); the identifier is underneath.
+		switch x := t.(type) {
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		}
+		if id, ok := t.(*ast.Ident); !ok || id.Name != tname {
+			continue
+		}
+		start := fn.Pos()
+		if fn.Doc != nil {
+			start = fn.Doc.Pos()
+		}
+		spans = append(spans, span{
+			start: prog.Fset.Position(start).Offset,
+			end:   prog.Fset.Position(fn.End()).Offset,
+		})
+	}
+	if len(spans) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	src := append([]byte(nil), orig...)
+	for _, s := range spans {
+		end := s.end
+		// Take the blank line that separated the method from its
+		// neighbor along with it.
+		for end < len(src) && (src[end] == '\n' || src[end] == '\r') {
+			end++
+		}
+		src = append(src[:s.start], src[end:]...)
+	}
+
+	// Pruning the now-unused imports is goimports' specialty; removal
+	// is purely syntactic and needs no package resolution.
+	final, err := imports.Process(filename, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pruning imports: %v", err)
+	}
+	return &Response{File: final, Edits: computeEdits(orig, final)}, nil
+}
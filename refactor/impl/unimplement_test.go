@@ -0,0 +1,116 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package impl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnimplement(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"events": {`package events
+
+type Event struct{}`},
+		"iface": {`package iface
+
+import "events"
+
+type Sink interface {
+	Send(e events.Event) error
+	Flush() error
+}`},
+		"conc": {`package conc
+
+import "events"
+
+type Job struct{}
+
+func (j *Job) Send(e events.Event) error {
+	panic("not implemented") // TODO: Implement
+}
+
+func (j *Job) Flush() error {
+	return nil // filled in by hand
+}
+
+func (j *Job) Other() {
+	panic("not implemented")
+}`},
+	})
+
+	resp, err := Unimplement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Sink",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "j *Job",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("no change reported")
+	}
+	src := string(resp.File)
+	if strings.Contains(src, "Send(") {
+		t.Errorf("stub method not removed:\n%s", src)
+	}
+	if strings.Contains(src, `"events"`) {
+		t.Errorf("unused import not pruned:\n%s", src)
+	}
+	// Flush was modified and Other is not an interface method; both
+	// stay.
+	if !strings.Contains(src, "func (j *Job) Flush() error {") {
+		t.Errorf("modified method removed:\n%s", src)
+	}
+	if !strings.Contains(src, "func (j *Job) Other() {") {
+		t.Errorf("unrelated method removed:\n%s", src)
+	}
+	if len(resp.Edits) == 0 {
+		t.Error("no edits reported")
+	}
+}
+
+// TestUnimplementGenericReceiver removes stubs whose receiver spells
+// type parameters, as Implement generates them for a generic concrete
+// type.
+func TestUnimplementGenericReceiver(t *testing.T) {
+	ctxt := fakeContext(map[string][]string{
+		"iface": {`package iface
+
+type Resetter interface {
+	Reset() error
+}`},
+		"conc": {`package conc
+
+type Cache[K comparable, V any] struct {
+	m map[K]V
+}
+
+func (c *Cache[K, V]) Reset() error {
+	panic("not implemented") // TODO: Implement
+}`},
+	})
+
+	resp, err := Unimplement(&Request{
+		Ctxt:         ctxt,
+		IfacePath:    "iface",
+		IfaceName:    "Resetter",
+		ConcretePath: "conc",
+		File:         "0.go",
+		Target:       "c *Cache[int, string]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("no change reported")
+	}
+	if src := string(resp.File); strings.Contains(src, "Reset(") {
+		t.Errorf("generic-receiver stub not removed:\n%s", src)
+	}
+}